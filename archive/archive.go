@@ -2,25 +2,37 @@ package archive
 
 import (
 	"compress/flate"
+	"errors"
+	"fmt"
 	"io"
+	"path/filepath"
 
+	"github.com/meltwater/drone-cache/archive/common"
 	"github.com/meltwater/drone-cache/archive/gzip"
+	"github.com/meltwater/drone-cache/archive/lz4"
 	"github.com/meltwater/drone-cache/archive/tar"
+	"github.com/meltwater/drone-cache/archive/xz"
+	"github.com/meltwater/drone-cache/archive/zip"
 	"github.com/meltwater/drone-cache/archive/zstd"
 
 	"github.com/go-kit/kit/log"
-	"github.com/go-kit/kit/log/level"
 )
 
 const (
 	Gzip = "gzip"
+	Lz4  = "lz4"
 	Tar  = "tar"
+	Xz   = "xz"
+	Zip  = "zip"
 	Zstd = "zstd"
 
 	DefaultCompressionLevel = flate.DefaultCompression
 	DefaultArchiveFormat    = Tar
 )
 
+// ErrUnknownFormat means that given archive format is not supported.
+var ErrUnknownFormat = errors.New("unknown archive format")
+
 // Archive is an interface that defines exposed behavior of archive formats.
 type Archive interface {
 	// Create writes content of the given source to an archive, returns written bytes.
@@ -34,8 +46,28 @@ type Archive interface {
 	Extract(dst string, r io.Reader) (int64, error)
 }
 
+// Lister is implemented by archive formats that can enumerate their entries without extracting them.
+type Lister interface {
+	// List reads the given archive reader and returns the entries it contains.
+	List(r io.Reader) ([]common.Entry, error)
+}
+
 // FromFormat determines which archive to use from given archive format.
-func FromFormat(logger log.Logger, root string, format string, opts ...Option) Archive {
+// Archives are created with given format, while restored with the format detected from their content.
+func FromFormat(logger log.Logger, root string, format string, opts ...Option) (Archive, error) {
+	if format == "" {
+		format = DefaultArchiveFormat
+	}
+
+	a, err := fromFormat(logger, root, format, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	return &detecting{logger: logger, format: format, root: root, opts: opts, Archive: a}, nil
+}
+
+func fromFormat(logger log.Logger, root string, format string, opts ...Option) (Archive, error) {
 	options := options{
 		compressionLevel: DefaultCompressionLevel,
 	}
@@ -44,15 +76,39 @@ func FromFormat(logger log.Logger, root string, format string, opts ...Option) A
 		o.apply(&options)
 	}
 
+	symlinks, err := common.ParseSymlinkPolicy(string(options.shared.Symlinks))
+	if err != nil {
+		return nil, err
+	}
+
+	options.shared.Symlinks = symlinks
+
+	for _, pattern := range options.shared.Excludes {
+		if _, err := filepath.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("exclude pattern <%s>, %w", pattern, err)
+		}
+	}
+
 	switch format {
 	case Gzip:
-		return gzip.New(logger, root, options.skipSymlinks, options.compressionLevel)
+		return gzip.NewWithOptions(logger, root, options.compressionLevel, options.shared), nil
+	case Lz4:
+		return lz4.NewWithOptions(logger, root, options.compressionLevel, options.shared), nil
+	case Xz:
+		return xz.NewWithOptions(logger, root, options.compressionLevel, options.shared), nil
 	case Zstd:
-		return zstd.New(logger, root, options.skipSymlinks, options.compressionLevel)
+		return zstd.NewWithOptions(logger, root, options.compressionLevel, options.shared), nil
+	case Zip:
+		method, err := zip.ParseMethod(options.zipMethod)
+		if err != nil {
+			return nil, err
+		}
+
+		return zip.NewWithOptions(logger, root, options.compressionLevel, method, options.shared), nil
 	case Tar:
-		return tar.New(logger, root, options.skipSymlinks)
+		return tar.NewWithOptions(logger, root, options.shared), nil
 	default:
-		level.Error(logger).Log("msg", "unknown archive format", "format", format)
-		return tar.New(logger, root, options.skipSymlinks) // DefaultArchiveFormat
+		return nil, fmt.Errorf("<%s>, (%s, %s, %s, %s, %s, %s), %w", format, Tar, Gzip, Zstd, Lz4, Xz, Zip,
+			ErrUnknownFormat)
 	}
 }
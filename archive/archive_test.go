@@ -0,0 +1,309 @@
+package archive
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/go-kit/kit/log"
+
+	"github.com/meltwater/drone-cache/archive/common"
+	"github.com/meltwater/drone-cache/test"
+)
+
+const (
+	benchRoot      = "testdata"
+	benchFiles     = 32
+	benchFileSize  = 512 * 1024
+	benchExtracted = "testdata/extracted"
+)
+
+var benchWords = strings.Fields("cache restore rebuild archive mount key namespace drone build pipeline step module " +
+	"dependency vendor node_modules target gradle maven cargo bundle lockfile checksum")
+
+func TestFromFormat(t *testing.T) {
+	t.Parallel()
+
+	for _, format := range []string{Tar, Gzip, Zstd, Lz4, Xz, Zip, ""} {
+		a, err := FromFormat(log.NewNopLogger(), "", format)
+		test.Ok(t, err)
+		test.Assert(t, a != nil, "archive for format %q must not be nil", format)
+	}
+
+	_, err := FromFormat(log.NewNopLogger(), "", "rar")
+	test.Assert(t, errors.Is(err, ErrUnknownFormat), "unknown format must be an error, got %v", err)
+
+	_, err = FromFormat(log.NewNopLogger(), "", Tar, WithSymlinkPolicy("dereference"))
+	test.Assert(t, errors.Is(err, common.ErrUnknownSymlinkPolicy), "unknown symlink policy must be an error, got %v", err)
+}
+
+func TestExtractDetectsFormat(t *testing.T) {
+	src := filepath.Join(benchRoot, "detect")
+	test.Ok(t, os.MkdirAll(src, 0755))
+	t.Cleanup(func() { os.RemoveAll(benchRoot) })
+
+	content := []byte(strings.Repeat("detect ", 1024))
+	test.Ok(t, os.WriteFile(filepath.Join(src, "file"), content, 0644))
+
+	restorer, err := FromFormat(log.NewNopLogger(), "", Gzip)
+	test.Ok(t, err)
+
+	for _, format := range []string{Tar, Gzip, Zstd, Lz4, Xz, Zip} {
+		format := format
+		t.Run(format, func(t *testing.T) {
+			a, err := FromFormat(log.NewNopLogger(), "", format)
+			test.Ok(t, err)
+
+			var buf bytes.Buffer
+			_, err = a.Create([]string{src}, &buf, true)
+			test.Ok(t, err)
+
+			detected, err := Detect(bufio.NewReader(bytes.NewReader(buf.Bytes())))
+			test.Ok(t, err)
+			test.Equals(t, format, detected)
+
+			entries, err := restorer.(Lister).List(bytes.NewReader(buf.Bytes()))
+			test.Ok(t, err)
+			test.Equals(t, 2, len(entries))
+
+			dst := filepath.Join(benchExtracted, format)
+			_, err = restorer.Extract(dst, bytes.NewReader(buf.Bytes()))
+			test.Ok(t, err)
+
+			got, err := os.ReadFile(filepath.Join(dst, "detect", "file"))
+			test.Ok(t, err)
+			test.Equals(t, content, got)
+		})
+	}
+}
+
+func TestDetectUnknown(t *testing.T) {
+	t.Parallel()
+
+	detected, err := Detect(bufio.NewReader(strings.NewReader("")))
+	test.Ok(t, err)
+	test.Equals(t, "", detected)
+
+	detected, err = Detect(bufio.NewReader(strings.NewReader("not an archive")))
+	test.Ok(t, err)
+	test.Equals(t, "", detected)
+}
+
+func TestAdaptiveCompression(t *testing.T) {
+	src := filepath.Join(benchRoot, "adaptive")
+	test.Ok(t, os.MkdirAll(src, 0755))
+	t.Cleanup(func() { os.RemoveAll(benchRoot) })
+
+	r := rand.New(rand.NewSource(1)) // nolint:gosec
+
+	// NOTICE: Stored files do not share content, stored blocks can not refer to earlier matches.
+	random := make([]byte, 384*1024)
+	r.Read(random) // nolint:errcheck
+
+	files := map[string][]byte{
+		"text":       []byte(strings.Repeat("adaptive ", 32*1024)),
+		"random.bin": random[:256*1024],
+		"lib.jar":    random[256*1024:],
+		"small":      []byte("small"),
+	}
+	for name, content := range files {
+		test.Ok(t, os.WriteFile(filepath.Join(src, name), content, 0644))
+	}
+
+	for _, format := range []string{Gzip, Zstd} {
+		format := format
+		t.Run(format, func(t *testing.T) {
+			plain, err := FromFormat(log.NewNopLogger(), "", format)
+			test.Ok(t, err)
+
+			a, err := FromFormat(log.NewNopLogger(), "", format, WithAdaptiveCompression(true))
+			test.Ok(t, err)
+
+			var plainBuf, buf bytes.Buffer
+			_, err = plain.Create([]string{src}, &plainBuf, true)
+			test.Ok(t, err)
+
+			_, err = a.Create([]string{src}, &buf, true)
+			test.Ok(t, err)
+
+			// Stored files must not make the archive noticeably bigger.
+			test.Assert(t, buf.Len() < plainBuf.Len()+plainBuf.Len()/50,
+				"adaptive archive must not be noticeably bigger, got %d, want about %d", buf.Len(), plainBuf.Len())
+
+			dst := filepath.Join(benchExtracted, format)
+			_, err = plain.Extract(dst, bytes.NewReader(buf.Bytes()))
+			test.Ok(t, err)
+
+			for name, content := range files {
+				got, err := os.ReadFile(filepath.Join(dst, "adaptive", name))
+				test.Ok(t, err)
+				test.Equals(t, content, got)
+			}
+		})
+	}
+}
+
+func TestLimits(t *testing.T) {
+	src := filepath.Join(benchRoot, "limits")
+	test.Ok(t, os.MkdirAll(src, 0755))
+	t.Cleanup(func() { os.RemoveAll(benchRoot) })
+
+	// A file of zeros compresses like a decompression bomb.
+	test.Ok(t, os.WriteFile(filepath.Join(src, "zeros"), make([]byte, 8<<20), 0644))
+	test.Ok(t, os.WriteFile(filepath.Join(src, "small"), []byte("small"), 0644))
+
+	for _, format := range []string{Tar, Gzip, Zstd, Lz4, Xz, Zip} {
+		format := format
+		t.Run(format, func(t *testing.T) {
+			unlimited, err := FromFormat(log.NewNopLogger(), "", format)
+			test.Ok(t, err)
+
+			var archived bytes.Buffer
+			_, err = unlimited.Create([]string{src}, &archived, true)
+			test.Ok(t, err)
+
+			for _, tc := range []struct {
+				name   string
+				limits common.Limits
+				ratio  bool // the ratio is only checked while extracting
+			}{
+				{name: "entries", limits: common.Limits{MaxEntries: 2}},
+				{name: "uncompressed size", limits: common.Limits{MaxUncompressedSize: 4 << 20}},
+				{name: "archive size", limits: common.Limits{MaxArchiveSize: int64(archived.Len()) / 2}},
+				{name: "ratio", limits: common.Limits{MaxRatio: 10}, ratio: true},
+			} {
+				if tc.ratio && format == Tar {
+					continue
+				}
+
+				a, err := FromFormat(log.NewNopLogger(), "", format, WithLimits(tc.limits))
+				test.Ok(t, err)
+
+				if !tc.ratio {
+					_, err = a.Create([]string{src}, io.Discard, true)
+					test.Assert(t, errors.Is(err, common.ErrLimitExceeded), "%s: create must fail, got %v", tc.name, err)
+				}
+
+				dst := filepath.Join(benchExtracted, format)
+				_, err = a.Extract(dst, bytes.NewReader(archived.Bytes()))
+				test.Assert(t, errors.Is(err, common.ErrLimitExceeded), "%s: extract must fail, got %v", tc.name, err)
+
+				test.Ok(t, os.RemoveAll(dst))
+			}
+		})
+	}
+}
+
+func BenchmarkCreate(b *testing.B) {
+	src, size := benchmarkFileTree(b)
+
+	for _, bc := range benchmarkCases() {
+		bc := bc
+		b.Run(bc.name, func(b *testing.B) {
+			a, err := FromFormat(log.NewNopLogger(), "", bc.format, bc.opts...)
+			test.Ok(b, err)
+
+			b.SetBytes(size)
+			b.ResetTimer()
+
+			for i := 0; i < b.N; i++ {
+				_, err := a.Create([]string{src}, io.Discard, true)
+				test.Ok(b, err)
+			}
+		})
+	}
+}
+
+func BenchmarkExtract(b *testing.B) {
+	src, size := benchmarkFileTree(b)
+
+	for _, bc := range benchmarkCases() {
+		bc := bc
+		b.Run(bc.name, func(b *testing.B) {
+			a, err := FromFormat(log.NewNopLogger(), "", bc.format, bc.opts...)
+			test.Ok(b, err)
+
+			var buf bytes.Buffer
+			_, err = a.Create([]string{src}, &buf, true)
+			test.Ok(b, err)
+
+			b.SetBytes(size)
+			b.ResetTimer()
+
+			for i := 0; i < b.N; i++ {
+				_, err := a.Extract(benchExtracted, bytes.NewReader(buf.Bytes()))
+				test.Ok(b, err)
+			}
+
+			b.StopTimer()
+			test.Ok(b, os.RemoveAll(benchExtracted))
+		})
+	}
+}
+
+// Helpers
+
+type benchmarkCase struct {
+	name   string
+	format string
+	opts   []Option
+}
+
+func benchmarkCases() []benchmarkCase {
+	return []benchmarkCase{
+		{name: Tar, format: Tar},
+		{name: Gzip + "/concurrency=1", format: Gzip, opts: []Option{WithConcurrency(1)}},
+		{name: Gzip + "/concurrency=all", format: Gzip},
+		{name: Zstd + "/concurrency=1", format: Zstd, opts: []Option{WithConcurrency(1)}},
+		{name: Zstd + "/concurrency=all", format: Zstd, opts: []Option{WithConcurrency(runtime.GOMAXPROCS(0))}},
+		{name: Zstd + "/concurrency=all,window=8MiB", format: Zstd, opts: []Option{WithWindowSize(8 << 20)}},
+		{name: Gzip + "/adaptive", format: Gzip, opts: []Option{WithAdaptiveCompression(true)}},
+		{name: Zstd + "/adaptive", format: Zstd, opts: []Option{WithAdaptiveCompression(true)}},
+		{name: Lz4, format: Lz4},
+		{name: Xz, format: Xz},
+		{name: Zip, format: Zip},
+		{name: Zip + "/zstd", format: Zip, opts: []Option{WithZipCompression("zstd")}},
+	}
+}
+
+// benchmarkFileTree creates a tree of half compressible text and half random files, returns its path and size.
+func benchmarkFileTree(b *testing.B) (string, int64) {
+	b.Helper()
+
+	dir := filepath.Join(benchRoot, "bench")
+	test.Ok(b, os.MkdirAll(dir, 0755))
+
+	b.Cleanup(func() {
+		os.RemoveAll(benchRoot)
+	})
+
+	r := rand.New(rand.NewSource(1)) // nolint:gosec
+
+	for i := 0; i < benchFiles; i++ {
+		content := make([]byte, 0, benchFileSize)
+
+		if i%2 == 0 {
+			for len(content) < benchFileSize {
+				content = append(content, benchWords[r.Intn(len(benchWords))]...)
+				content = append(content, ' ')
+			}
+
+			content = content[:benchFileSize]
+		} else {
+			content = content[:benchFileSize]
+			r.Read(content) // nolint:errcheck
+		}
+
+		test.Ok(b, os.WriteFile(filepath.Join(dir, fmt.Sprintf("file_%d", i)), content, 0644))
+	}
+
+	return dir, benchFiles * benchFileSize
+}
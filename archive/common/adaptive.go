@@ -0,0 +1,178 @@
+package common
+
+import (
+	"bufio"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	// AdaptiveThreshold is the minimum size of a file to be stored without recompression,
+	// smaller files are not worth switching members for.
+	AdaptiveThreshold = 64 * 1024
+
+	sampleSize     = 16 * 1024
+	entropyCeiling = 7.5 // bits per byte
+)
+
+// incompressibleExtensions are file types that are already compressed.
+var incompressibleExtensions = map[string]bool{
+	".7z": true, ".aar": true, ".apk": true, ".avif": true, ".br": true, ".bz2": true, ".crate": true,
+	".deb": true, ".ear": true, ".egg": true, ".gem": true, ".gif": true, ".gz": true, ".jar": true,
+	".jpeg": true, ".jpg": true, ".lz4": true, ".mp3": true, ".mp4": true, ".nupkg": true, ".pack": true,
+	".png": true, ".rar": true, ".rpm": true, ".tbz2": true, ".tgz": true, ".txz": true, ".war": true,
+	".webm": true, ".webp": true, ".whl": true, ".woff": true, ".woff2": true, ".xz": true, ".zip": true,
+	".zst": true,
+}
+
+// Incompressible reports whether the file at given path is not worth compressing, either from its extension
+// or from the entropy of a sample of its content.
+func Incompressible(path string, fi os.FileInfo) bool {
+	if fi.Size() < AdaptiveThreshold {
+		return false
+	}
+
+	if incompressibleExtensions[strings.ToLower(filepath.Ext(path))] {
+		return true
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+
+	buf := make([]byte, sampleSize)
+
+	n, err := io.ReadFull(f, buf)
+	if err != nil && err != io.ErrUnexpectedEOF {
+		return false
+	}
+
+	return entropy(buf[:n]) >= entropyCeiling
+}
+
+// IncompressibleStream is like Incompressible for content that is streamed, with given name and size.
+// It peeks a sample of the content from given reader, without consuming it.
+func IncompressibleStream(name string, size int64, r *bufio.Reader) bool {
+	if size < AdaptiveThreshold {
+		return false
+	}
+
+	if incompressibleExtensions[strings.ToLower(filepath.Ext(name))] {
+		return true
+	}
+
+	sample, err := r.Peek(sampleSize)
+	if err != nil && err != io.EOF {
+		return false
+	}
+
+	return entropy(sample) >= entropyCeiling
+}
+
+// NewSampleReader returns a reader that IncompressibleStream can peek a sample from.
+func NewSampleReader(r io.Reader) *bufio.Reader {
+	return bufio.NewReaderSize(r, sampleSize)
+}
+
+// entropy returns the Shannon entropy of given data in bits per byte.
+func entropy(b []byte) float64 {
+	if len(b) == 0 {
+		return 0
+	}
+
+	var counts [256]int
+	for _, c := range b {
+		counts[c]++
+	}
+
+	var e float64
+
+	for _, c := range counts {
+		if c == 0 {
+			continue
+		}
+
+		p := float64(c) / float64(len(b))
+		e -= p * math.Log2(p)
+	}
+
+	return e
+}
+
+// ResetWriteCloser is a compressing writer that can be reused for a new stream.
+type ResetWriteCloser interface {
+	io.WriteCloser
+	Reset(w io.Writer)
+}
+
+// MemberWriter writes a stream of concatenated compressed members, switching between a compressing and
+// a storing writer. Formats that decode concatenated members as a single stream read it like any other archive.
+type MemberWriter struct {
+	w io.Writer
+
+	compress ResetWriteCloser
+	store    ResetWriteCloser
+	cur      ResetWriteCloser
+}
+
+// NewMemberWriter creates a new MemberWriter, that starts with the compressing writer.
+func NewMemberWriter(w io.Writer, compress, store ResetWriteCloser) *MemberWriter {
+	return &MemberWriter{w: w, compress: compress, store: store}
+}
+
+// Switch finishes the current member and starts a new one, if the given mode differs from the current one.
+func (m *MemberWriter) Switch(store bool) error {
+	next := m.compress
+	if store {
+		next = m.store
+	}
+
+	if m.cur == next {
+		return nil
+	}
+
+	if m.cur != nil {
+		if err := m.cur.Close(); err != nil {
+			return err
+		}
+	}
+
+	next.Reset(m.w)
+	m.cur = next
+
+	return nil
+}
+
+// Write writes to the current member.
+func (m *MemberWriter) Write(p []byte) (int, error) {
+	if m.cur == nil {
+		if err := m.Switch(false); err != nil {
+			return 0, err
+		}
+	}
+
+	return m.cur.Write(p)
+}
+
+// Close finishes the current member.
+func (m *MemberWriter) Close() error {
+	if m.cur == nil {
+		if err := m.Switch(false); err != nil {
+			return err
+		}
+	}
+
+	return m.cur.Close()
+}
+
+// Hook returns a tar entry hook that stores incompressible files and compresses everything else.
+func (m *MemberWriter) Hook() func(path string, fi os.FileInfo) error {
+	return func(path string, fi os.FileInfo) error {
+		return m.Switch(Incompressible(path, fi))
+	}
+}
@@ -0,0 +1,43 @@
+package common
+
+import (
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/meltwater/drone-cache/test"
+)
+
+func TestIncompressible(t *testing.T) {
+	t.Parallel()
+
+	dir, cleanUp := test.CreateTempDir(t, "incompressible")
+	t.Cleanup(cleanUp)
+
+	random := make([]byte, AdaptiveThreshold)
+	rand.New(rand.NewSource(1)).Read(random) // nolint:errcheck,gosec
+
+	text := []byte(strings.Repeat("compressible ", AdaptiveThreshold/10))
+
+	for _, tc := range []struct {
+		name    string
+		content []byte
+		want    bool
+	}{
+		{name: "text", content: text, want: false},
+		{name: "text.jar", content: text, want: true},
+		{name: "text.TGZ", content: text, want: true},
+		{name: "random", content: random, want: true},
+		{name: "small.jar", content: []byte("small"), want: false},
+	} {
+		path := filepath.Join(dir, tc.name)
+		test.Ok(t, os.WriteFile(path, tc.content, 0644))
+
+		fi, err := os.Stat(path)
+		test.Ok(t, err)
+
+		test.Equals(t, tc.want, Incompressible(path, fi))
+	}
+}
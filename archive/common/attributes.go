@@ -0,0 +1,16 @@
+package common
+
+import "os"
+
+// Attributes defines how owners, modes and modification times of extracted entries are restored,
+// the zero value restores modes of the archive subject to the process umask, and leaves the rest to the system.
+type Attributes struct {
+	// PreserveOwner restores the owners of the archive, it requires root privileges.
+	// Otherwise extracted entries are owned by the user running the extraction.
+	PreserveOwner bool
+	// ModeMask holds permission bits that are cleared from modes of the archive, like a umask.
+	// If it is set, the resulting modes are applied exactly, regardless of the process umask.
+	ModeMask os.FileMode
+	// PreserveModTime restores modification times of the archive, instead of the time of the extraction.
+	PreserveModTime bool
+}
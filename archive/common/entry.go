@@ -0,0 +1,15 @@
+package common
+
+import (
+	"os"
+	"time"
+)
+
+// Entry defines a single item stored in an archive.
+type Entry struct {
+	Name     string      `json:"name"`
+	Linkname string      `json:"linkname,omitempty"`
+	Size     int64       `json:"size_bytes"`
+	Mode     os.FileMode `json:"mode"`
+	ModTime  time.Time   `json:"mod_time"`
+}
@@ -0,0 +1,183 @@
+package common
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/dustin/go-humanize"
+)
+
+// ratioFloor is the uncompressed size below which the compression ratio is not checked,
+// small archives of repetitive content legitimately have high ratios.
+const ratioFloor = 1 << 20
+
+// ErrLimitExceeded means that an archive exceeds one of the configured limits.
+var ErrLimitExceeded = errors.New("archive limit exceeded")
+
+// Limits defines the maximum sizes of archives, zero values disable the limit.
+type Limits struct {
+	// MaxArchiveSize is the maximum size of the compressed archive in bytes.
+	MaxArchiveSize int64
+	// MaxUncompressedSize is the maximum size of the uncompressed tar stream in bytes.
+	MaxUncompressedSize int64
+	// MaxEntries is the maximum number of entries in the archive.
+	MaxEntries int64
+	// MaxRatio is the maximum ratio of uncompressed to compressed size, it is checked while reading archives.
+	MaxRatio float64
+}
+
+// Guard enforces limits on a single archive operation, it counts compressed and uncompressed bytes and entries.
+// Once a limit is exceeded, every following call returns the same error.
+type Guard struct {
+	limits Limits
+
+	mu           sync.Mutex
+	archive      int64
+	uncompressed int64
+	entries      int64
+	err          error
+}
+
+// NewGuard creates a new Guard.
+func NewGuard(limits Limits) *Guard {
+	return &Guard{limits: limits}
+}
+
+// Err returns the error of the first exceeded limit.
+func (g *Guard) Err() error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	return g.err
+}
+
+// Capture sets given error to the error of the first exceeded limit, unless it is already set.
+// It is deferred before closing writers, as they flush buffered output on close.
+func (g *Guard) Capture(err *error) {
+	if *err != nil {
+		return
+	}
+
+	*err = g.Err()
+}
+
+// Entry counts an entry with given declared size, it fails before the entry is written if it would exceed a limit.
+func (g *Guard) Entry(name string, size int64) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.err != nil {
+		return g.err
+	}
+
+	g.entries++
+
+	if g.limits.MaxEntries > 0 && g.entries > g.limits.MaxEntries {
+		return g.fail("more than %d entries, at <%s>", g.limits.MaxEntries, name)
+	}
+
+	if g.limits.MaxUncompressedSize > 0 && g.uncompressed+size > g.limits.MaxUncompressedSize {
+		return g.fail("uncompressed size would exceed %s, at <%s> of %s", humanize.Bytes(uint64(g.limits.MaxUncompressedSize)),
+			name, humanize.Bytes(uint64(size)))
+	}
+
+	return nil
+}
+
+// ArchiveWriter counts bytes written to the compressed archive.
+func (g *Guard) ArchiveWriter(w io.Writer) io.Writer {
+	return &countingWriter{w, g.addArchive}
+}
+
+// ArchiveReader counts bytes read from the compressed archive.
+func (g *Guard) ArchiveReader(r io.Reader) io.Reader {
+	return &countingReader{r, g.addArchive}
+}
+
+// Writer counts bytes written to the uncompressed tar stream.
+func (g *Guard) Writer(w io.Writer) io.Writer {
+	return &countingWriter{w, g.addUncompressed(false)}
+}
+
+// Reader counts bytes read from the uncompressed tar stream, and checks the compression ratio.
+func (g *Guard) Reader(r io.Reader) io.Reader {
+	return &countingReader{r, g.addUncompressed(true)}
+}
+
+func (g *Guard) addArchive(n int) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.err != nil {
+		return g.err
+	}
+
+	g.archive += int64(n)
+
+	if g.limits.MaxArchiveSize > 0 && g.archive > g.limits.MaxArchiveSize {
+		return g.fail("archive size exceeds %s", humanize.Bytes(uint64(g.limits.MaxArchiveSize)))
+	}
+
+	return nil
+}
+
+func (g *Guard) addUncompressed(checkRatio bool) func(int) error {
+	return func(n int) error {
+		g.mu.Lock()
+		defer g.mu.Unlock()
+
+		if g.err != nil {
+			return g.err
+		}
+
+		g.uncompressed += int64(n)
+
+		if g.limits.MaxUncompressedSize > 0 && g.uncompressed > g.limits.MaxUncompressedSize {
+			return g.fail("uncompressed size exceeds %s", humanize.Bytes(uint64(g.limits.MaxUncompressedSize)))
+		}
+
+		// NOTICE: Compressed bytes are read ahead by decoders, so the ratio is never overestimated while reading.
+		// Encoders buffer their output, which is why the ratio is not checked while writing.
+		if checkRatio && g.limits.MaxRatio > 0 && g.archive > 0 && g.uncompressed > ratioFloor &&
+			float64(g.uncompressed)/float64(g.archive) > g.limits.MaxRatio {
+			return g.fail("compression ratio exceeds %.0f", g.limits.MaxRatio)
+		}
+
+		return nil
+	}
+}
+
+func (g *Guard) fail(format string, args ...interface{}) error {
+	g.err = fmt.Errorf(format+", %w", append(args, ErrLimitExceeded)...)
+
+	return g.err
+}
+
+type countingWriter struct {
+	w   io.Writer
+	add func(int) error
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	if err := c.add(len(p)); err != nil {
+		return 0, err
+	}
+
+	return c.w.Write(p)
+}
+
+type countingReader struct {
+	r   io.Reader
+	add func(int) error
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	if aErr := c.add(n); aErr != nil {
+		return n, aErr
+	}
+
+	return n, err
+}
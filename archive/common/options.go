@@ -0,0 +1,22 @@
+package common
+
+// Options defines settings shared by archive formats, zero values use the defaults of each format.
+type Options struct {
+	// Symlinks defines how symbolic links are archived, empty is SymlinkPreserve.
+	Symlinks SymlinkPolicy
+	// Concurrency is the number of goroutines used to compress and decompress archives, by formats that support it.
+	Concurrency int
+	// WindowSize is the encoder window of formats that support it, it must be a power of two.
+	WindowSize int
+	// Adaptive stores incompressible files without recompressing them, by formats that support it.
+	Adaptive bool
+	// ExtractConcurrency is the number of goroutines used to write extracted files.
+	ExtractConcurrency int
+	// Limits are checked when archives are created and extracted.
+	Limits Limits
+	// Attributes define how owners, modes and modification times of extracted entries are restored.
+	Attributes Attributes
+	// Excludes holds glob patterns of paths relative to archived sources, that are left out of archives
+	// with everything below them.
+	Excludes []string
+}
@@ -0,0 +1,51 @@
+package common
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrUnknownSymlinkPolicy means that given symbolic link policy is not supported.
+var ErrUnknownSymlinkPolicy = errors.New("unknown symbolic link policy")
+
+// SymlinkPolicy defines how symbolic links are archived.
+type SymlinkPolicy string
+
+const (
+	// SymlinkPreserve archives symbolic links as links.
+	SymlinkPreserve SymlinkPolicy = "preserve"
+	// SymlinkFollow archives the content that symbolic links point to, instead of the links.
+	SymlinkFollow SymlinkPolicy = "follow"
+	// SymlinkSkip leaves symbolic links out of archives.
+	SymlinkSkip SymlinkPolicy = "skip"
+	// SymlinkFollowWithinRoot follows symbolic links that point into the archive root or the archived directory,
+	// other links are preserved.
+	SymlinkFollowWithinRoot SymlinkPolicy = "follow-within-root"
+)
+
+// ParseSymlinkPolicy parses given symbolic link policy, an empty value is SymlinkPreserve.
+func ParseSymlinkPolicy(s string) (SymlinkPolicy, error) {
+	switch p := SymlinkPolicy(s); p {
+	case "":
+		return SymlinkPreserve, nil
+	case SymlinkPreserve, SymlinkFollow, SymlinkSkip, SymlinkFollowWithinRoot:
+		return p, nil
+	default:
+		return "", fmt.Errorf("<%s>, (%s, %s, %s, %s), %w", s, SymlinkPreserve, SymlinkFollow, SymlinkSkip,
+			SymlinkFollowWithinRoot, ErrUnknownSymlinkPolicy)
+	}
+}
+
+// SkipSymlinks returns SymlinkSkip if skip is true, and SymlinkPreserve otherwise.
+func SkipSymlinks(skip bool) SymlinkPolicy {
+	if skip {
+		return SymlinkSkip
+	}
+
+	return SymlinkPreserve
+}
+
+// Follows returns true if symbolic links are followed, at least those that point into the archive root.
+func (p SymlinkPolicy) Follows() bool {
+	return p == SymlinkFollow || p == SymlinkFollowWithinRoot
+}
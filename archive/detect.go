@@ -0,0 +1,108 @@
+package archive
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+
+	"github.com/meltwater/drone-cache/archive/common"
+)
+
+const (
+	tarMagicOffset = 257
+	peekSize       = 512
+)
+
+var magics = []struct {
+	format string
+	offset int
+	magic  []byte
+}{
+	{Gzip, 0, []byte{0x1f, 0x8b}},
+	{Zstd, 0, []byte{0x28, 0xb5, 0x2f, 0xfd}},
+	{Lz4, 0, []byte{0x04, 0x22, 0x4d, 0x18}},
+	{Xz, 0, []byte{0xfd, '7', 'z', 'X', 'Z', 0x00}},
+	{Zip, 0, []byte("PK\x03\x04")},
+	{Zip, 0, []byte("PK\x05\x06")}, // empty archive
+	{Tar, tarMagicOffset, []byte("ustar")},
+}
+
+// Detect returns the archive format of given reader from its magic bytes, without consuming them.
+// It returns an empty string if the format is not recognized.
+func Detect(r *bufio.Reader) (string, error) {
+	b, err := r.Peek(peekSize)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return "", fmt.Errorf("peek archive header, %w", err)
+	}
+
+	for _, m := range magics {
+		if len(b) >= m.offset+len(m.magic) && bytes.Equal(b[m.offset:m.offset+len(m.magic)], m.magic) {
+			return m.format, nil
+		}
+	}
+
+	return "", nil
+}
+
+// detecting creates archives with the configured format, and reads archives with the format detected from their
+// magic bytes. So that archives written with a previously configured format can still be restored.
+type detecting struct {
+	logger log.Logger
+
+	format string
+	root   string
+	opts   []Option
+
+	Archive
+}
+
+// Extract reads content from the given archive reader and restores it to the destination, returns written bytes.
+func (d *detecting) Extract(dst string, r io.Reader) (int64, error) {
+	br := bufio.NewReaderSize(r, peekSize)
+
+	a, err := d.detect(br)
+	if err != nil {
+		return 0, err
+	}
+
+	return a.Extract(dst, br)
+}
+
+// List reads content from the given archive reader and returns its entries, without extracting them.
+func (d *detecting) List(r io.Reader) ([]common.Entry, error) {
+	br := bufio.NewReaderSize(r, peekSize)
+
+	a, err := d.detect(br)
+	if err != nil {
+		return nil, err
+	}
+
+	l, ok := a.(Lister)
+	if !ok {
+		return nil, errors.New("archive format does not support listing entries")
+	}
+
+	return l.List(br)
+}
+
+func (d *detecting) detect(r *bufio.Reader) (Archive, error) {
+	format, err := Detect(r)
+	if err != nil {
+		return nil, err
+	}
+
+	// NOTICE: Unrecognized archives, like empty tar archives, are read with the configured format.
+	if format == "" || format == d.format {
+		return d.Archive, nil
+	}
+
+	level.Info(d.logger).Log("msg", "archive format differs from configured format, using detected format",
+		"configured", d.format, "detected", format)
+
+	return fromFormat(d.logger, d.root, format, d.opts...)
+}
@@ -4,7 +4,11 @@ import (
 	"compress/gzip"
 	"fmt"
 	"io"
+	"runtime"
 
+	"github.com/klauspost/pgzip"
+
+	"github.com/meltwater/drone-cache/archive/common"
 	"github.com/meltwater/drone-cache/archive/tar"
 	"github.com/meltwater/drone-cache/internal"
 
@@ -17,36 +21,129 @@ type Archive struct {
 
 	root             string
 	compressionLevel int
-	skipSymlinks     bool
+	opts             common.Options
 }
 
+// blockSize is the size of blocks that are compressed and decompressed in parallel.
+const blockSize = 1 << 20
+
 // New creates an archive that uses the .tar.gz file format.
 func New(logger log.Logger, root string, skipSymlinks bool, compressionLevel int) *Archive {
-	return &Archive{logger, root, compressionLevel, skipSymlinks}
+	return NewWithOptions(logger, root, compressionLevel, common.Options{Symlinks: common.SkipSymlinks(skipSymlinks)})
+}
+
+// NewWithOptions creates an archive that uses the .tar.gz file format with given options.
+// Blocks are compressed in parallel by Concurrency goroutines, 0 uses all available CPUs,
+// 1 uses the single-threaded standard library implementation. The output is a standard gzip stream either way.
+// If Adaptive is set, incompressible files are stored in uncompressed gzip members instead of being recompressed.
+// The rest of the options apply to the tar stream, see tar.NewWithOptions.
+func NewWithOptions(logger log.Logger, root string, compressionLevel int, opts common.Options) *Archive {
+	if opts.Concurrency <= 0 {
+		opts.Concurrency = runtime.GOMAXPROCS(0)
+	}
+
+	return &Archive{logger: logger, root: root, compressionLevel: compressionLevel, opts: opts}
 }
 
 // Create writes content of the given source to an archive, returns written bytes.
 // If isRelativePath is true, it clones using the path, else it clones using a path
 // combining archive's root with the path.
-func (a *Archive) Create(srcs []string, w io.Writer, isRelativePath bool) (int64, error) {
-	gw, err := gzip.NewWriterLevel(w, a.compressionLevel)
+func (a *Archive) Create(srcs []string, w io.Writer, isRelativePath bool) (written int64, err error) {
+	g := common.NewGuard(a.opts.Limits)
+	defer g.Capture(&err)
+
+	w = g.ArchiveWriter(w)
+
+	gw, err := a.newWriter(w)
 	if err != nil {
 		return 0, fmt.Errorf("create archive writer, %w", err)
 	}
 
-	defer internal.CloseWithErrLogf(a.logger, gw, "gzip writer")
+	if !a.opts.Adaptive {
+		defer internal.CloseWithErrLogf(a.logger, gw, "gzip writer")
+
+		return a.newTar(g).Create(srcs, gw, isRelativePath)
+	}
+
+	// NOTICE: Concatenated gzip members are read as a single stream.
+	sw, err := gzip.NewWriterLevel(w, gzip.NoCompression)
+	if err != nil {
+		return 0, fmt.Errorf("create archive store writer, %w", err)
+	}
+
+	mw := common.NewMemberWriter(w, gw, sw)
+	defer internal.CloseWithErrLogf(a.logger, mw, "gzip writer")
 
-	return tar.New(a.logger, a.root, a.skipSymlinks).Create(srcs, gw, isRelativePath)
+	return a.newTar(g).CreateWithHook(srcs, mw, isRelativePath, mw.Hook())
 }
 
 // Extract reads content from the given archive reader and restores it to the destination, returns written bytes.
 func (a *Archive) Extract(dst string, r io.Reader) (int64, error) {
-	gr, err := gzip.NewReader(r)
+	g := common.NewGuard(a.opts.Limits)
+
+	gr, err := a.newReader(g.ArchiveReader(r))
 	if err != nil {
 		return 0, err
 	}
 
 	defer internal.CloseWithErrLogf(a.logger, gr, "gzip reader")
 
-	return tar.New(a.logger, a.root, a.skipSymlinks).Extract(dst, gr)
+	return a.newTar(g).Extract(dst, gr)
+}
+
+// List reads content from the given archive reader and returns its entries, without extracting them.
+func (a *Archive) List(r io.Reader) ([]common.Entry, error) {
+	g := common.NewGuard(a.opts.Limits)
+
+	gr, err := a.newReader(g.ArchiveReader(r))
+	if err != nil {
+		return nil, err
+	}
+
+	defer internal.CloseWithErrLogf(a.logger, gr, "gzip reader")
+
+	return a.newTar(g).List(gr)
+}
+
+func (a *Archive) newTar(g *common.Guard) *tar.Archive {
+	return tar.NewWithOptions(a.logger, a.root, a.opts).WithGuard(g)
+}
+
+func (a *Archive) newWriter(w io.Writer) (common.ResetWriteCloser, error) {
+	if a.opts.Concurrency == 1 {
+		return gzip.NewWriterLevel(w, a.compressionLevel)
+	}
+
+	gw, err := pgzip.NewWriterLevel(w, a.compressionLevel)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := gw.SetConcurrency(blockSize, a.opts.Concurrency); err != nil {
+		return nil, err
+	}
+
+	return &parallelWriter{gw, a.opts.Concurrency}, nil
+}
+
+func (a *Archive) newReader(r io.Reader) (io.ReadCloser, error) {
+	if a.opts.Concurrency == 1 {
+		return gzip.NewReader(r)
+	}
+
+	// NOTICE: Inflating a gzip stream is sequential, blocks are read ahead and decompressed in the background.
+	return pgzip.NewReaderN(r, blockSize, a.opts.Concurrency)
+}
+
+// parallelWriter keeps the configured concurrency of a pgzip writer across resets.
+type parallelWriter struct {
+	*pgzip.Writer
+
+	concurrency int
+}
+
+// Reset discards the writer's state and makes it equivalent to a new writer, writing to given writer.
+func (w *parallelWriter) Reset(dst io.Writer) {
+	w.Writer.Reset(dst)
+	w.Writer.SetConcurrency(blockSize, w.concurrency) // nolint:errcheck // valid values are checked on creation.
 }
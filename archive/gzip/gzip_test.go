@@ -1,8 +1,10 @@
 package gzip
 
 import (
+	"bytes"
 	"compress/flate"
 	"compress/gzip"
+	"fmt"
 	"io"
 	"io/ioutil"
 	"os"
@@ -12,6 +14,7 @@ import (
 
 	"github.com/go-kit/kit/log"
 
+	"github.com/meltwater/drone-cache/archive/common"
 	"github.com/meltwater/drone-cache/archive/tar"
 	"github.com/meltwater/drone-cache/test"
 )
@@ -392,3 +395,29 @@ func exampleFileTreeWithSymlinks(t *testing.T, name string) []string {
 
 	return []string{file, dir, symlink}
 }
+
+func TestParallelCompatible(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	for i := 0; i < 3; i++ {
+		content := []byte(strings.Repeat(fmt.Sprintf("content %d ", i), 1<<16))
+		test.Ok(t, ioutil.WriteFile(filepath.Join(dir, fmt.Sprintf("file_%d", i)), content, 0644))
+	}
+
+	parallel := NewWithOptions(log.NewNopLogger(), testRootMounted, flate.DefaultCompression, common.Options{Concurrency: 4})
+	single := NewWithOptions(log.NewNopLogger(), testRootMounted, flate.DefaultCompression, common.Options{Concurrency: 1})
+
+	var buf bytes.Buffer
+	_, err := parallel.Create([]string{dir}, &buf, true)
+	test.Ok(t, err)
+
+	// Parallel output must be a standard gzip stream.
+	want, err := parallel.List(bytes.NewReader(buf.Bytes()))
+	test.Ok(t, err)
+
+	got, err := single.List(bytes.NewReader(buf.Bytes()))
+	test.Ok(t, err)
+	test.Equals(t, len(want), 4)
+	test.Equals(t, want, got)
+}
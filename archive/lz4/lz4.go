@@ -0,0 +1,122 @@
+package lz4
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/go-kit/log"
+	"github.com/pierrec/lz4/v4"
+
+	"github.com/meltwater/drone-cache/archive/common"
+	"github.com/meltwater/drone-cache/archive/tar"
+	"github.com/meltwater/drone-cache/internal"
+)
+
+// Archive implements archive for lz4.
+type Archive struct {
+	logger log.Logger
+
+	root             string
+	compressionLevel int
+	opts             common.Options
+}
+
+// New creates an archive that uses the .tar.lz4 file format.
+func New(logger log.Logger, root string, skipSymlinks bool, compressionLevel int) *Archive {
+	return NewWithOptions(logger, root, compressionLevel, common.Options{Symlinks: common.SkipSymlinks(skipSymlinks)})
+}
+
+// NewWithOptions creates an archive that uses the .tar.lz4 file format with given options.
+// Concurrency sets the number of goroutines used by the encoder and decoder, 0 uses all available CPUs.
+// The rest of the options apply to the tar stream, see tar.NewWithOptions.
+func NewWithOptions(logger log.Logger, root string, compressionLevel int, opts common.Options) *Archive {
+	return &Archive{logger: logger, root: root, compressionLevel: compressionLevel, opts: opts}
+}
+
+// Create writes content of the given source to an archive, returns written bytes.
+func (a *Archive) Create(srcs []string, w io.Writer, isRelativePath bool) (written int64, err error) {
+	g := common.NewGuard(a.opts.Limits)
+	defer g.Capture(&err)
+
+	w = g.ArchiveWriter(w)
+
+	lw := lz4.NewWriter(w)
+	if err := lw.Apply(
+		lz4.CompressionLevelOption(compressionLevel(a.compressionLevel)),
+		lz4.ConcurrencyOption(a.opts.Concurrency),
+	); err != nil {
+		return 0, fmt.Errorf("lz4 create archive writer, %w", err)
+	}
+
+	defer internal.CloseWithErrLogf(a.logger, lw, "lz4 writer")
+
+	wBytes, err := a.newTar(g).Create(srcs, lw, isRelativePath)
+	if err != nil {
+		return 0, fmt.Errorf("lz4 create archive, %w", err)
+	}
+
+	return wBytes, nil
+}
+
+// Extract reads content from the given archive reader and restores it to the destination, returns written bytes.
+func (a *Archive) Extract(dst string, r io.Reader) (int64, error) {
+	g := common.NewGuard(a.opts.Limits)
+
+	lr, err := a.newReader(g.ArchiveReader(r))
+	if err != nil {
+		return 0, fmt.Errorf("lz4 create extract archive reader, %w", err)
+	}
+
+	eBytes, err := a.newTar(g).Extract(dst, lr)
+	if err != nil {
+		return 0, fmt.Errorf("lz4 extract archive, %w", err)
+	}
+
+	return eBytes, nil
+}
+
+// List reads content from the given archive reader and returns its entries, without extracting them.
+func (a *Archive) List(r io.Reader) ([]common.Entry, error) {
+	g := common.NewGuard(a.opts.Limits)
+
+	lr, err := a.newReader(g.ArchiveReader(r))
+	if err != nil {
+		return nil, fmt.Errorf("lz4 create list archive reader, %w", err)
+	}
+
+	entries, err := a.newTar(g).List(lr)
+	if err != nil {
+		return nil, fmt.Errorf("lz4 list archive, %w", err)
+	}
+
+	return entries, nil
+}
+
+func (a *Archive) newTar(g *common.Guard) *tar.Archive {
+	return tar.NewWithOptions(a.logger, a.root, a.opts).WithGuard(g)
+}
+
+func (a *Archive) newReader(r io.Reader) (io.Reader, error) {
+	lr := lz4.NewReader(r)
+	if err := lr.Apply(lz4.ConcurrencyOption(a.opts.Concurrency)); err != nil {
+		return nil, err
+	}
+
+	return lr, nil
+}
+
+var levels = []lz4.CompressionLevel{
+	lz4.Fast, lz4.Level1, lz4.Level2, lz4.Level3, lz4.Level4, lz4.Level5, lz4.Level6, lz4.Level7, lz4.Level8, lz4.Level9,
+}
+
+// compressionLevel maps given flate style level to an lz4 level, default and levels below 1 use the fast mode.
+func compressionLevel(level int) lz4.CompressionLevel {
+	switch {
+	case level < 1:
+		return lz4.Fast
+	case level >= len(levels):
+		return lz4.Level9
+	default:
+		return levels[level]
+	}
+}
@@ -0,0 +1,76 @@
+package lz4
+
+import (
+	"bytes"
+	"compress/flate"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/go-kit/log"
+
+	"github.com/meltwater/drone-cache/test"
+)
+
+var (
+	testRoot          = "testdata"
+	testRootMounted   = "testdata/mounted"
+	testRootExtracted = "testdata/extracted"
+)
+
+func TestCreateAndExtract(t *testing.T) {
+	test.Ok(t, os.MkdirAll(testRootMounted, 0755))
+	test.Ok(t, os.MkdirAll(testRootExtracted, 0755))
+	t.Cleanup(func() { os.RemoveAll(testRoot) })
+
+	src, srcClean := test.CreateTempDir(t, "lz4_create", testRootMounted)
+	t.Cleanup(srcClean)
+
+	for i := 0; i < 3; i++ {
+		content := []byte(strings.Repeat(fmt.Sprintf("content %d\n", i), 1024))
+		test.Ok(t, os.WriteFile(filepath.Join(src, fmt.Sprintf("file_%d", i)), content, 0644))
+	}
+
+	for _, level := range []int{flate.DefaultCompression, flate.NoCompression, flate.BestSpeed, flate.BestCompression} {
+		level := level
+		t.Run(fmt.Sprintf("level %d", level), func(t *testing.T) {
+			a := New(log.NewNopLogger(), testRootMounted, false, level)
+
+			var buf bytes.Buffer
+			written, err := a.Create([]string{src}, &buf, false)
+			test.Ok(t, err)
+			test.Equals(t, int64(3*10*1024), written)
+			test.Assert(t, int64(buf.Len()) < written, "archive must be compressed, got %d bytes", buf.Len())
+
+			entries, err := a.List(bytes.NewReader(buf.Bytes()))
+			test.Ok(t, err)
+			test.Equals(t, 4, len(entries)) // directory and 3 files
+
+			dst, dstClean := test.CreateTempDir(t, "lz4_extract", testRootExtracted)
+			t.Cleanup(dstClean)
+
+			extracted, err := a.Extract(dst, bytes.NewReader(buf.Bytes()))
+			test.Ok(t, err)
+			test.Equals(t, written, extracted)
+
+			for i := 0; i < 3; i++ {
+				name := fmt.Sprintf("file_%d", i)
+				want, err := os.ReadFile(filepath.Join(src, name))
+				test.Ok(t, err)
+
+				got, err := os.ReadFile(filepath.Join(dst, filepath.Base(src), name))
+				test.Ok(t, err)
+				test.Equals(t, want, got)
+			}
+		})
+	}
+}
+
+func TestExtractInvalid(t *testing.T) {
+	a := New(log.NewNopLogger(), testRootMounted, false, flate.DefaultCompression)
+
+	_, err := a.Extract(testRootExtracted, strings.NewReader("not a tar.lz4 archive"))
+	test.NotOk(t, err)
+}
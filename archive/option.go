@@ -1,8 +1,13 @@
 package archive
 
+import "github.com/meltwater/drone-cache/archive/common"
+
 type options struct {
 	compressionLevel int
-	skipSymlinks     bool
+	zipMethod        string
+
+	// shared holds options that are passed to every archive format.
+	shared common.Options
 }
 
 // Option overrides behavior of Archive.
@@ -23,9 +28,77 @@ func WithCompressionLevel(i int) Option {
 	})
 }
 
-// WithSkipSymlinks sets skip symlink option.
+// WithSkipSymlinks sets skip symlink option, it is equivalent to WithSymlinkPolicy(common.SymlinkSkip).
 func WithSkipSymlinks(b bool) Option {
 	return optionFunc(func(o *options) {
-		o.skipSymlinks = b
+		if b {
+			o.shared.Symlinks = common.SymlinkSkip
+		}
+	})
+}
+
+// WithSymlinkPolicy sets how symbolic links are archived, an empty policy keeps the current one.
+func WithSymlinkPolicy(p common.SymlinkPolicy) Option {
+	return optionFunc(func(o *options) {
+		if p != "" {
+			o.shared.Symlinks = p
+		}
+	})
+}
+
+// WithConcurrency sets number of goroutines used to compress and decompress archives, 0 uses all available CPUs.
+func WithConcurrency(i int) Option {
+	return optionFunc(func(o *options) {
+		o.shared.Concurrency = i
+	})
+}
+
+// WithWindowSize sets the window size of compression formats that support it, it must be a power of two.
+func WithWindowSize(i int) Option {
+	return optionFunc(func(o *options) {
+		o.shared.WindowSize = i
+	})
+}
+
+// WithAdaptiveCompression sets adaptive compression option, formats that support it store incompressible files
+// without recompressing them.
+func WithAdaptiveCompression(b bool) Option {
+	return optionFunc(func(o *options) {
+		o.shared.Adaptive = b
+	})
+}
+
+// WithExtractConcurrency sets number of goroutines used to write extracted files, 0 uses four per available CPU.
+func WithExtractConcurrency(i int) Option {
+	return optionFunc(func(o *options) {
+		o.shared.ExtractConcurrency = i
+	})
+}
+
+// WithLimits sets limits that archives are checked against when they are created and extracted.
+func WithLimits(l common.Limits) Option {
+	return optionFunc(func(o *options) {
+		o.shared.Limits = l
+	})
+}
+
+// WithAttributes sets how owners, modes and modification times of extracted entries are restored.
+func WithAttributes(a common.Attributes) Option {
+	return optionFunc(func(o *options) {
+		o.shared.Attributes = a
+	})
+}
+
+// WithExcludes sets glob patterns of paths relative to archived sources, that are left out of archives.
+func WithExcludes(patterns []string) Option {
+	return optionFunc(func(o *options) {
+		o.shared.Excludes = patterns
+	})
+}
+
+// WithZipCompression sets the compression method of zip entries (deflate, zstd, store), empty uses deflate.
+func WithZipCompression(method string) Option {
+	return optionFunc(func(o *options) {
+		o.zipMethod = method
 	})
 }
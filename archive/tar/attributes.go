@@ -0,0 +1,100 @@
+package tar
+
+import (
+	"archive/tar"
+	"fmt"
+	"os"
+
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+
+	"github.com/meltwater/drone-cache/archive/common"
+)
+
+// modeBits are the bits of a file mode that are changed by os.Chmod.
+const modeBits = os.ModePerm | os.ModeSetuid | os.ModeSetgid | os.ModeSticky
+
+// attributes restores owners, modes and modification times of the entries of a single extraction.
+type attributes struct {
+	common.Attributes
+
+	chown bool
+	chmod bool
+
+	// dirs holds extracted directories, their modes and modification times are restored after their content,
+	// it is only accessed by the reading goroutine.
+	dirs []dirAttributes
+}
+
+type dirAttributes struct {
+	h      *tar.Header
+	target string
+}
+
+func newAttributes(logger log.Logger, attrs common.Attributes) *attributes {
+	chown := attrs.PreserveOwner && os.Geteuid() == 0
+	if attrs.PreserveOwner && !chown {
+		level.Warn(logger).Log("msg", "owners are not restored, it requires root privileges") //nolint: errcheck
+	}
+
+	// NOTICE: Changing the owner clears setuid and setgid bits, modes are applied again afterwards.
+	return &attributes{Attributes: attrs, chown: chown, chmod: chown || attrs.ModeMask != 0}
+}
+
+// restore applies the attributes of given header to the entry extracted to target.
+func (r *attributes) restore(h *tar.Header, target string) error {
+	if h.Typeflag == tar.TypeLink { // hard links share the attributes of their target
+		return nil
+	}
+
+	if r.chown {
+		if err := os.Lchown(target, h.Uid, h.Gid); err != nil {
+			return fmt.Errorf("change owner of <%s>, %w", target, err)
+		}
+	}
+
+	switch h.Typeflag {
+	case tar.TypeSymlink: // modes and times of symbolic links are those of their target
+		return nil
+	case tar.TypeDir:
+		// NOTICE: Directories are changed last, read-only directories could not be filled otherwise.
+		r.dirs = append(r.dirs, dirAttributes{h, target})
+
+		return nil
+	}
+
+	return r.apply(h, target)
+}
+
+// finish restores modes and modification times of extracted directories, once their content is extracted.
+func (r *attributes) finish() error {
+	for i := len(r.dirs) - 1; i >= 0; i-- {
+		if err := r.apply(r.dirs[i].h, r.dirs[i].target); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (r *attributes) apply(h *tar.Header, target string) error {
+	if r.chmod {
+		mode := h.FileInfo().Mode() & modeBits &^ r.ModeMask
+		if err := os.Chmod(target, mode); err != nil {
+			return fmt.Errorf("change mode of <%s>, %w", target, err)
+		}
+	}
+
+	if r.PreserveModTime {
+		atime := h.AccessTime
+		if atime.IsZero() {
+			atime = h.ModTime
+		}
+
+		if err := os.Chtimes(target, atime, h.ModTime); err != nil {
+			return fmt.Errorf("change times of <%s>, %w", target, err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,67 @@
+//go:build !windows
+
+package tar
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/meltwater/drone-cache/archive/common"
+	"github.com/meltwater/drone-cache/test"
+
+	"github.com/go-kit/kit/log"
+)
+
+func TestAttributes(t *testing.T) {
+	src := filepath.Join(testRootMounted, "attributes")
+	dst := filepath.Join(testRootExtracted, "attributes")
+
+	test.Ok(t, os.MkdirAll(filepath.Join(src, "dir"), 0755))
+	t.Cleanup(func() { os.RemoveAll(testRoot) })
+
+	file := filepath.Join(src, "dir", "file")
+	test.Ok(t, ioutil.WriteFile(file, []byte("attributes"), 0644))
+	test.Ok(t, os.Chmod(file, 0666))
+	test.Ok(t, os.Chmod(filepath.Join(src, "dir"), 0777))
+
+	mtime := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	test.Ok(t, os.Chtimes(file, mtime, mtime))
+	test.Ok(t, os.Chtimes(filepath.Join(src, "dir"), mtime, mtime))
+
+	owner := os.Geteuid() == 0
+	if owner {
+		test.Ok(t, os.Lchown(file, 1234, 5678))
+	}
+
+	var buf bytes.Buffer
+	_, err := New(log.NewNopLogger(), testRootMounted, false).Create([]string{src}, &buf, true)
+	test.Ok(t, err)
+
+	a := NewWithOptions(log.NewNopLogger(), testRootMounted, common.Options{
+		Attributes: common.Attributes{PreserveOwner: true, ModeMask: 0027, PreserveModTime: true},
+	})
+	_, err = a.Extract(dst, bytes.NewReader(buf.Bytes()))
+	test.Ok(t, err)
+
+	for path, mode := range map[string]os.FileMode{"dir/file": 0640, "dir": os.ModeDir | 0750} {
+		fi, err := os.Stat(filepath.Join(dst, "mounted", "attributes", path))
+		test.Ok(t, err)
+		test.Equals(t, mode, fi.Mode())
+		test.Assert(t, fi.ModTime().Equal(mtime), "modification time of <%s> must be restored, got %v", path,
+			fi.ModTime())
+	}
+
+	if owner {
+		fi, err := os.Stat(filepath.Join(dst, "mounted", "attributes", "dir", "file"))
+		test.Ok(t, err)
+
+		st := fi.Sys().(*syscall.Stat_t)
+		test.Equals(t, uint32(1234), st.Uid)
+		test.Equals(t, uint32(5678), st.Gid)
+	}
+}
@@ -0,0 +1,24 @@
+//go:build !windows
+
+package tar
+
+import (
+	"os"
+	"syscall"
+)
+
+// fileID identifies a file by its device and inode number.
+type fileID struct {
+	dev uint64
+	ino uint64
+}
+
+// hardLinkID returns the id of given file, if the file has more than one hard link.
+func hardLinkID(fi os.FileInfo) (fileID, bool) {
+	st, ok := fi.Sys().(*syscall.Stat_t)
+	if !ok || st.Nlink < 2 {
+		return fileID{}, false
+	}
+
+	return fileID{dev: uint64(st.Dev), ino: uint64(st.Ino)}, true // nolint:unconvert // types differ between platforms.
+}
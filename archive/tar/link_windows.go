@@ -0,0 +1,17 @@
+//go:build windows
+
+package tar
+
+import "os"
+
+// fileID identifies a file by its device and inode number.
+type fileID struct {
+	dev uint64
+	ino uint64
+}
+
+// hardLinkID returns the id of given file, if the file has more than one hard link.
+// Hard links are not tracked on Windows, every link is archived as a regular file.
+func hardLinkID(fi os.FileInfo) (fileID, bool) {
+	return fileID{}, false
+}
@@ -0,0 +1,99 @@
+package tar
+
+import (
+	"archive/tar"
+	"bytes"
+	"sync"
+)
+
+// maxBufferedFileSize is the size up to which regular files are read into memory and written by the pool,
+// bigger files are written by the goroutine that reads the archive.
+const maxBufferedFileSize = 1 << 20
+
+// pool writes extracted regular files with a bounded number of goroutines.
+// The archive is still decoded sequentially, only file creation and writes run in parallel.
+type pool struct {
+	sem chan struct{}
+	wg  sync.WaitGroup
+
+	restore func(h *tar.Header, target string) error
+
+	mu      sync.Mutex
+	written int64
+	err     error
+
+	// pending holds targets that are possibly being written, with channels that are closed once they are done.
+	// It is only accessed by the reading goroutine.
+	pending map[string]chan struct{}
+}
+
+// newPool creates a pool of given size, that calls restore once a file is written.
+func newPool(concurrency int, restore func(h *tar.Header, target string) error) *pool {
+	return &pool{sem: make(chan struct{}, concurrency), restore: restore, pending: map[string]chan struct{}{}}
+}
+
+// Go writes given content to target and restores its attributes in the background,
+// it blocks while all workers are busy.
+func (p *pool) Go(h *tar.Header, content []byte, target string) error {
+	if err := p.Err(); err != nil {
+		return err
+	}
+
+	p.sem <- struct{}{}
+	p.wg.Add(1)
+	done := make(chan struct{})
+	p.pending[target] = done
+
+	go func() {
+		defer func() {
+			close(done)
+			<-p.sem
+			p.wg.Done()
+		}()
+
+		n, err := extractRegular(h, bytes.NewReader(content), target)
+		if err == nil {
+			err = p.restore(h, target)
+		}
+
+		p.mu.Lock()
+		defer p.mu.Unlock()
+
+		p.written += n
+		if err != nil && p.err == nil {
+			p.err = err
+		}
+	}()
+
+	return nil
+}
+
+// Sync waits for the pending write of given target, if there is one. So that entries written later to the same path
+// keep the order of the archive, and links are only created to completely written files.
+func (p *pool) Sync(target string) error {
+	if done, ok := p.pending[target]; ok {
+		<-done
+		delete(p.pending, target)
+	}
+
+	return p.Err()
+}
+
+// Wait blocks until all pending writes are done, returns the written bytes so far and the first error.
+func (p *pool) Wait() (int64, error) {
+	p.wg.Wait()
+	p.pending = map[string]chan struct{}{}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.written, p.err
+}
+
+// Err returns the first error of a finished write.
+func (p *pool) Err() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.err
+}
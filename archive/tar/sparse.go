@@ -0,0 +1,263 @@
+package tar
+
+import (
+	"archive/tar"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/meltwater/drone-cache/internal"
+)
+
+const (
+	blockSize = 512
+
+	// sparseBlockSize is the size of zero blocks that are skipped instead of written, when sparse files are restored.
+	sparseBlockSize = 4096
+
+	sparsePrefix = "GNU.sparse."
+)
+
+var zeroBlock = make([]byte, sparseBlockSize)
+
+// region is a part of a sparse file that holds data.
+type region struct {
+	offset int64
+	length int64
+}
+
+// writeSparse writes given file in the GNU sparse 1.0 PAX format, which GNU tar, bsdtar and archive/tar understand.
+// Only data regions are stored, preceded by a map of them. Returns written bytes of data.
+func writeSparse(s *createState, h *tar.Header, f *os.File, regions []region) (int64, error) {
+	realSize := h.Size
+
+	// NOTICE: Files can grow between their stat and the search for their data.
+	// Data beyond the size in the header is refused, like tar.Writer refuses it for regular files.
+	for _, r := range regions {
+		if r.offset+r.length > realSize {
+			return 0, fmt.Errorf("data region of <%s> at %d ends beyond its size %d, %w",
+				h.Name, r.offset+r.length, realSize, tar.ErrWriteTooLong)
+		}
+	}
+
+	// NOTICE: A zero length region at the end records trailing holes, like GNU tar does.
+	if len(regions) == 0 || regions[len(regions)-1].offset+regions[len(regions)-1].length < realSize {
+		regions = append(regions, region{offset: realSize})
+	}
+
+	var (
+		sparseMap []byte
+		dataSize  int64
+	)
+
+	sparseMap = append(strconv.AppendInt(sparseMap, int64(len(regions)), 10), '\n')
+	for _, r := range regions {
+		sparseMap = append(strconv.AppendInt(sparseMap, r.offset, 10), '\n')
+		sparseMap = append(strconv.AppendInt(sparseMap, r.length, 10), '\n')
+		dataSize += r.length
+	}
+
+	sparseMap = append(sparseMap, make([]byte, padding(int64(len(sparseMap))))...)
+
+	dir, file := path.Split(h.Name)
+	name := path.Join(dir, "GNUSparseFile.0", file)
+	size := int64(len(sparseMap)) + dataSize
+
+	// NOTICE: archive/tar refuses to write GNU sparse PAX records, headers of sparse files are encoded here.
+	// The entry is written past tar.Writer, which writes straight to the output once the previous entry is flushed.
+	records := map[string]string{
+		sparsePrefix + "major":    "1",
+		sparsePrefix + "minor":    "0",
+		sparsePrefix + "name":     h.Name,
+		sparsePrefix + "realsize": strconv.FormatInt(realSize, 10),
+		"path":                    name,
+	}
+
+	if size > maxOctal(12) {
+		records["size"] = strconv.FormatInt(size, 10)
+	}
+
+	if int64(h.Uid) > maxOctal(8) {
+		records["uid"] = strconv.Itoa(h.Uid)
+	}
+
+	if int64(h.Gid) > maxOctal(8) {
+		records["gid"] = strconv.Itoa(h.Gid)
+	}
+
+	if len(h.Uname) > 32 {
+		records["uname"] = h.Uname
+	}
+
+	if len(h.Gname) > 32 {
+		records["gname"] = h.Gname
+	}
+
+	pax := paxRecords(records)
+
+	var headers bytes.Buffer
+	headers.Write(ustarHeader(&tar.Header{Typeflag: tar.TypeXHeader, Name: path.Join(dir, "PaxHeaders.0", file),
+		Mode: 0644, Size: int64(len(pax)), ModTime: h.ModTime}))
+	headers.Write(pax)
+	headers.Write(make([]byte, padding(int64(len(pax)))))
+	headers.Write(ustarHeader(&tar.Header{Typeflag: tar.TypeReg, Name: name, Mode: h.Mode, Uid: h.Uid, Gid: h.Gid,
+		Uname: h.Uname, Gname: h.Gname, Size: size, ModTime: h.ModTime}))
+
+	if err := s.tw.Flush(); err != nil {
+		return 0, fmt.Errorf("flush previous entry, %w", err)
+	}
+
+	for _, b := range [][]byte{headers.Bytes(), sparseMap} {
+		if _, err := s.out.Write(b); err != nil {
+			return 0, fmt.Errorf("write sparse header for <%s>, %w", h.Name, err)
+		}
+	}
+
+	var written int64
+
+	for _, r := range regions {
+		n, err := io.Copy(s.out, io.NewSectionReader(f, r.offset, r.length))
+		written += n
+
+		if err != nil {
+			return written, fmt.Errorf("copy data region of <%s>, %w", h.Name, err)
+		}
+
+		// The data is written past tar.Writer, which would otherwise notice files that shrank meanwhile.
+		if n != r.length {
+			return written, fmt.Errorf("copy data region of <%s>, wrote %d of %d bytes, %w", h.Name, n, r.length, io.ErrShortWrite)
+		}
+	}
+
+	if _, err := s.out.Write(make([]byte, padding(dataSize))); err != nil {
+		return written, fmt.Errorf("pad sparse file <%s>, %w", h.Name, err)
+	}
+
+	return written, nil
+}
+
+// ustarHeader encodes a ustar header block of given header with its checksum, as defined by POSIX.
+// Names and values that do not fit are truncated, they are expected to be given by PAX records.
+func ustarHeader(h *tar.Header) []byte {
+	b := make([]byte, blockSize)
+
+	copy(b[0:100], h.Name)
+	putOctal(b[100:108], h.Mode)
+	putOctal(b[108:116], int64(h.Uid))
+	putOctal(b[116:124], int64(h.Gid))
+	putOctal(b[124:136], h.Size)
+	putOctal(b[136:148], h.ModTime.Unix())
+	b[156] = h.Typeflag
+	copy(b[257:265], "ustar\x0000")
+	copy(b[265:297], h.Uname)
+	copy(b[297:329], h.Gname)
+
+	// The checksum is the sum of all bytes of the header, with the checksum field itself counted as spaces.
+	copy(b[148:156], "        ")
+
+	var sum int64
+	for _, c := range b {
+		sum += int64(c)
+	}
+
+	copy(b[148:156], fmt.Sprintf("%06o\x00 ", sum))
+
+	return b
+}
+
+// putOctal writes given value as zero padded octal number terminated by NUL, values that do not fit are left zero.
+func putOctal(b []byte, v int64) {
+	if v < 0 || v > maxOctal(len(b)) {
+		v = 0
+	}
+
+	s := strconv.FormatInt(v, 8)
+	copy(b, strings.Repeat("0", len(b)-1-len(s))+s)
+}
+
+// maxOctal returns the greatest value of a NUL terminated octal field of given size.
+func maxOctal(size int) int64 {
+	return 1<<(3*(size-1)) - 1
+}
+
+// paxRecords encodes given records as the content of a PAX extended header, sorted by key.
+// Every record is "<length> <key>=<value>\n", where the length includes the length itself.
+func paxRecords(records map[string]string) []byte {
+	keys := make([]string, 0, len(records))
+	for k := range records {
+		keys = append(keys, k)
+	}
+
+	sort.Strings(keys)
+
+	var b []byte
+
+	for _, k := range keys {
+		rec := " " + k + "=" + records[k] + "\n"
+
+		n := len(rec) + len(strconv.Itoa(len(rec)))
+		if len(strconv.Itoa(n)) > len(strconv.Itoa(len(rec))) {
+			n++
+		}
+
+		b = append(strconv.AppendInt(b, int64(n), 10), rec...)
+	}
+
+	return b
+}
+
+// isSparse reports whether given header describes a sparse file, in any of the GNU formats.
+func isSparse(h *tar.Header) bool {
+	return h.Typeflag == tar.TypeGNUSparse ||
+		h.PAXRecords[sparsePrefix+"major"] != "" || h.PAXRecords[sparsePrefix+"map"] != ""
+}
+
+// extractSparse writes a sparse file, blocks of zeros are skipped to leave holes in the restored file.
+func extractSparse(h *tar.Header, r io.Reader, target string) (n int64, err error) {
+	f, err := os.OpenFile(target, os.O_CREATE|os.O_RDWR|os.O_TRUNC, os.FileMode(h.Mode))
+	if err != nil {
+		return 0, fmt.Errorf("open extracted file for writing <%s>, %w", target, err)
+	}
+
+	defer internal.CloseWithErrCapturef(&err, f, "extract sparse <%s>", target)
+
+	var (
+		buf = make([]byte, sparseBlockSize)
+		off int64
+	)
+
+	for {
+		m, rErr := io.ReadFull(r, buf)
+		if m > 0 && !bytes.Equal(buf[:m], zeroBlock[:m]) {
+			if _, err := f.WriteAt(buf[:m], off); err != nil {
+				return off, fmt.Errorf("write extracted file <%s>, %w", target, err)
+			}
+		}
+
+		off += int64(m)
+
+		if rErr == io.EOF || rErr == io.ErrUnexpectedEOF {
+			break
+		}
+
+		if rErr != nil {
+			return off, fmt.Errorf("read sparse file <%s>, %w", target, rErr)
+		}
+	}
+
+	if err := f.Truncate(off); err != nil {
+		return off, fmt.Errorf("truncate extracted file <%s>, %w", target, err)
+	}
+
+	return off, nil
+}
+
+// padding returns the number of bytes to pad given size to a multiple of the tar block size.
+func padding(size int64) int64 {
+	return -size & (blockSize - 1)
+}
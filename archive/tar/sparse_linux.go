@@ -0,0 +1,64 @@
+//go:build linux
+
+package tar
+
+import (
+	"errors"
+	"io"
+	"os"
+	"syscall"
+)
+
+// Whence values of lseek(2) to find data and holes in sparse files.
+const (
+	seekData = 3
+	seekHole = 4
+)
+
+// dataRegions returns the regions of given file that hold data, or nil if the file is not sparse.
+func dataRegions(f *os.File, size int64) ([]region, error) {
+	fi, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	st, ok := fi.Sys().(*syscall.Stat_t)
+	if !ok || st.Blocks*512 >= size {
+		return nil, nil
+	}
+
+	regions := []region{}
+
+	for off := int64(0); off < size; {
+		data, err := f.Seek(off, seekData)
+		if errors.Is(err, syscall.ENXIO) { // no more data after offset
+			break
+		}
+
+		if errors.Is(err, syscall.EINVAL) { // filesystem does not support seeking data
+			return nil, nil
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		hole, err := f.Seek(data, seekHole)
+		if err != nil {
+			return nil, err
+		}
+
+		regions = append(regions, region{offset: data, length: hole - data})
+		off = hole
+	}
+
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	if len(regions) == 1 && regions[0].length == size {
+		return nil, nil
+	}
+
+	return regions, nil
+}
@@ -0,0 +1,185 @@
+package tar
+
+import (
+	"archive/tar"
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+
+	"github.com/go-kit/kit/log"
+
+	"github.com/meltwater/drone-cache/test"
+)
+
+func TestSparseFiles(t *testing.T) {
+	src := filepath.Join(testRootMounted, "sparse")
+	dst := filepath.Join(testRootExtracted, "sparse")
+
+	test.Ok(t, os.MkdirAll(src, 0755))
+	t.Cleanup(func() { os.RemoveAll(testRoot) })
+
+	const size = 8 << 20
+
+	data := []byte("data in the middle of holes")
+
+	f, err := os.Create(filepath.Join(src, "file"))
+	test.Ok(t, err)
+	test.Ok(t, f.Truncate(size))
+	_, err = f.WriteAt(data, size/2)
+	test.Ok(t, err)
+	test.Ok(t, f.Close())
+
+	if allocated(t, filepath.Join(src, "file")) >= size {
+		t.Skip("filesystem does not support sparse files")
+	}
+
+	a := New(log.NewNopLogger(), testRootMounted, false)
+
+	var buf bytes.Buffer
+	_, err = a.Create([]string{src}, &buf, true)
+	test.Ok(t, err)
+	test.Assert(t, buf.Len() < 64*1024, "sparse file must be archived without holes, archive is %d bytes", buf.Len())
+
+	// Archives are readable by any reader that understands GNU sparse files.
+	tr := tar.NewReader(bytes.NewReader(buf.Bytes()))
+
+	_, err = tr.Next() // directory
+	test.Ok(t, err)
+
+	h, err := tr.Next()
+	test.Ok(t, err)
+	test.Equals(t, filepath.Join(src, "file"), h.Name)
+	test.Equals(t, int64(size), h.Size)
+
+	content, err := io.ReadAll(tr)
+	test.Ok(t, err)
+	test.Equals(t, data, content[size/2:size/2+len(data)])
+
+	_, err = a.Extract(dst, bytes.NewReader(buf.Bytes()))
+	test.Ok(t, err)
+
+	extracted := filepath.Join(dst, "mounted", "sparse", "file")
+
+	got, err := os.ReadFile(extracted)
+	test.Ok(t, err)
+	test.Assert(t, bytes.Equal(content, got), "extracted file must have the same content")
+	test.Assert(t, allocated(t, extracted) < size, "extracted file must be sparse")
+}
+
+func TestSparseFilesGNUTar(t *testing.T) {
+	version, err := exec.Command("tar", "--version").Output()
+	if err != nil || !strings.Contains(string(version), "GNU tar") {
+		t.Skip("GNU tar is not available")
+	}
+
+	src := filepath.Join(testRootMounted, "sparse-gnu")
+	test.Ok(t, os.MkdirAll(src, 0755))
+	t.Cleanup(func() { os.RemoveAll(testRoot) })
+
+	const size = 8 << 20
+
+	f, err := os.Create(filepath.Join(src, "file"))
+	test.Ok(t, err)
+	test.Ok(t, f.Truncate(size))
+	_, err = f.WriteAt([]byte("head"), 0)
+	test.Ok(t, err)
+	_, err = f.WriteAt([]byte("middle"), size/2)
+	test.Ok(t, err)
+	test.Ok(t, f.Close())
+
+	if allocated(t, filepath.Join(src, "file")) >= size {
+		t.Skip("filesystem does not support sparse files")
+	}
+
+	want, err := os.ReadFile(filepath.Join(src, "file"))
+	test.Ok(t, err)
+
+	a := New(log.NewNopLogger(), testRootMounted, false)
+
+	t.Run("extracted by GNU tar", func(t *testing.T) {
+		var buf bytes.Buffer
+		_, err := a.Create([]string{src}, &buf, true)
+		test.Ok(t, err)
+
+		dir := t.TempDir()
+		archive := filepath.Join(dir, "archive.tar")
+		test.Ok(t, os.WriteFile(archive, buf.Bytes(), 0644))
+
+		out, err := exec.Command("tar", "-xf", archive, "-C", dir).CombinedOutput()
+		test.Assert(t, err == nil, "GNU tar must extract the archive: %v, %s", err, out)
+
+		got, err := os.ReadFile(filepath.Join(dir, src, "file"))
+		test.Ok(t, err)
+		test.Assert(t, bytes.Equal(want, got), "file extracted by GNU tar must have the same content")
+		test.Assert(t, allocated(t, filepath.Join(dir, src, "file")) < size, "file extracted by GNU tar must be sparse")
+	})
+
+	t.Run("created by GNU tar", func(t *testing.T) {
+		dir := t.TempDir()
+		archive := filepath.Join(dir, "archive.tar")
+
+		out, err := exec.Command("tar", "--format=pax", "--sparse", "--sparse-version=1.0",
+			"-cf", archive, src).CombinedOutput()
+		test.Assert(t, err == nil, "GNU tar must create the archive: %v, %s", err, out)
+
+		b, err := os.ReadFile(archive)
+		test.Ok(t, err)
+
+		dst := filepath.Join(testRootExtracted, "sparse-gnu")
+		_, err = a.Extract(dst, bytes.NewReader(b))
+		test.Ok(t, err)
+
+		extracted := filepath.Join(dst, "mounted", "sparse-gnu", "file")
+
+		got, err := os.ReadFile(extracted)
+		test.Ok(t, err)
+		test.Assert(t, bytes.Equal(want, got), "file created by GNU tar must have the same content")
+		test.Assert(t, allocated(t, extracted) < size, "extracted file must be sparse")
+	})
+}
+
+func TestWriteSparseChangedFile(t *testing.T) {
+	test.Ok(t, os.MkdirAll(testRootMounted, 0755))
+	t.Cleanup(func() { os.RemoveAll(testRoot) })
+
+	f, err := os.Create(filepath.Join(testRootMounted, "file"))
+	test.Ok(t, err)
+	defer f.Close()
+
+	_, err = f.WriteAt([]byte("data"), 4096)
+	test.Ok(t, err)
+
+	for _, tc := range []struct {
+		name    string
+		size    int64
+		regions []region
+		err     error
+	}{
+		{name: "grown", size: 4096, regions: []region{{offset: 4096, length: 4}}, err: tar.ErrWriteTooLong},
+		{name: "shrunk", size: 8192, regions: []region{{offset: 4096, length: 4096}}, err: io.ErrShortWrite},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			var buf bytes.Buffer
+
+			s := &createState{tw: tar.NewWriter(&buf), out: &buf}
+			_, err := writeSparse(s, &tar.Header{Name: "file", Size: tc.size, Mode: 0644}, f, tc.regions)
+			test.Assert(t, errors.Is(err, tc.err), "expected %v, got %v", tc.err, err)
+		})
+	}
+}
+
+func allocated(t *testing.T, path string) int64 {
+	t.Helper()
+
+	fi, err := os.Stat(path)
+	test.Ok(t, err)
+
+	return fi.Sys().(*syscall.Stat_t).Blocks * 512
+}
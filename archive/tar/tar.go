@@ -7,11 +7,13 @@ import (
 	"io"
 	"os"
 	"path/filepath"
+	"runtime"
 	"strings"
 
 	"github.com/go-kit/kit/log"
 	"github.com/go-kit/kit/log/level"
 
+	"github.com/meltwater/drone-cache/archive/common"
 	"github.com/meltwater/drone-cache/internal"
 )
 
@@ -24,27 +26,94 @@ var (
 	ErrArchiveNotReadable = errors.New("archive not readable")
 )
 
+// EntryHook is called with the path and file info of every regular file, before it is written to the archive.
+type EntryHook func(path string, fi os.FileInfo) error
+
 // Archive implements archive for tar.
 type Archive struct {
 	logger log.Logger
 
-	root         string
-	skipSymlinks bool
+	root string
+	opts common.Options
+
+	guard         *common.Guard
+	skipHardLinks bool
 }
 
 // New creates an archive that uses the .tar file format.
 func New(logger log.Logger, root string, skipSymlinks bool) *Archive {
-	return &Archive{logger, root, skipSymlinks}
+	return NewWithOptions(logger, root, common.Options{Symlinks: common.SkipSymlinks(skipSymlinks)})
+}
+
+// NewWithOptions creates an archive that uses the .tar file format with given options.
+// Symbolic links are archived according to the Symlinks policy. Regular files are extracted by ExtractConcurrency
+// goroutines, 0 uses four per available CPU, 1 extracts every file sequentially.
+// Archives that exceed Limits are neither created nor extracted.
+// Owners, modes and modification times of extracted entries are restored according to Attributes.
+func NewWithOptions(logger log.Logger, root string, opts common.Options) *Archive {
+	if opts.Symlinks == "" {
+		opts.Symlinks = common.SymlinkPreserve
+	}
+
+	if opts.ExtractConcurrency <= 0 {
+		// NOTICE: Extracting is mostly waiting for the filesystem, not the CPU.
+		opts.ExtractConcurrency = 4 * runtime.GOMAXPROCS(0)
+	}
+
+	return &Archive{logger: logger, root: root, opts: opts}
+}
+
+// WithGuard returns a copy of the archive that enforces limits with given guard, instead of a guard of its own.
+// Compressing archives use it to count compressed and uncompressed bytes of an operation with the same guard.
+func (a *Archive) WithGuard(g *common.Guard) *Archive {
+	c := *a
+	c.guard = g
+
+	return &c
+}
+
+// WithoutHardLinks returns a copy of the archive that archives every hard link of a file as a regular file.
+// Formats that can not represent links use it to convert tar streams.
+func (a *Archive) WithoutHardLinks() *Archive {
+	c := *a
+	c.skipHardLinks = true
+
+	return &c
+}
+
+// newGuard returns the guard for a single operation. If the archive has no guard, the tar stream is the archive itself.
+func (a *Archive) newGuard() (*common.Guard, bool) {
+	if a.guard != nil {
+		return a.guard, false
+	}
+
+	return common.NewGuard(a.opts.Limits), true
 }
 
 // Create writes content of the given source to an archive, returns written bytes.
 // If isRelativePath is true, it clones using the path, else it clones using a path
 // combining archive's root with the path.
 func (a *Archive) Create(srcs []string, w io.Writer, isRelativePath bool) (int64, error) {
-	tw := tar.NewWriter(w)
+	return a.CreateWithHook(srcs, w, isRelativePath, nil)
+}
+
+// CreateWithHook is like Create, but calls given hook before every regular file is written to the archive.
+func (a *Archive) CreateWithHook(srcs []string, w io.Writer, isRelativePath bool,
+	hook EntryHook) (written int64, err error) {
+	g, own := a.newGuard()
+	if own {
+		w = g.ArchiveWriter(w)
+	}
+
+	defer g.Capture(&err)
+
+	out := g.Writer(w)
+	tw := tar.NewWriter(out)
+
 	defer internal.CloseWithErrLogf(a.logger, tw, "tar writer")
 
-	var written int64
+	s := &createState{tw: tw, out: out, hook: hook, guard: g, logger: a.logger, symlinks: a.opts.Symlinks, root: a.root,
+		excludes: a.opts.Excludes, links: map[fileID]string{}, skipHardLinks: a.skipHardLinks}
 
 	for _, src := range srcs {
 		_, err := os.Lstat(src)
@@ -52,16 +121,40 @@ func (a *Archive) Create(srcs []string, w io.Writer, isRelativePath bool) (int64
 			return written, fmt.Errorf("make sure file or directory readable <%s>: %v,, %w", src, err, ErrSourceNotReachable)
 		}
 
-		if err := filepath.Walk(src, writeToArchive(tw, a.root, a.skipSymlinks, &written, isRelativePath, a.logger)); err != nil {
-			return written, fmt.Errorf("walk, add all files to archive, %w", err)
+		s.src = src
+
+		if err := filepath.Walk(src, writeToArchive(s, a.root, isRelativePath, a.logger)); err != nil {
+			return s.written, fmt.Errorf("walk, add all files to archive, %w", err)
 		}
 	}
 
-	return written, nil
+	return s.written, nil
 }
 
-// nolint: lll
-func writeToArchive(tw *tar.Writer, root string, skipSymlinks bool, written *int64, isRelativePath bool, logger log.Logger) func(string, os.FileInfo, error) error {
+// createState holds the state of a single Create operation.
+type createState struct {
+	tw  *tar.Writer
+	out io.Writer // the stream tw writes to
+
+	hook   EntryHook
+	guard  *common.Guard
+	logger log.Logger
+
+	symlinks common.SymlinkPolicy
+	root     string
+	src      string // the source that is currently walked
+	excludes []string
+	// following holds the real directories of followed symbolic links and of the directories containing them,
+	// from the source to the link that is currently followed.
+	following []string
+
+	skipHardLinks bool
+	// links maps files with multiple hard links to the name they were first archived with.
+	links   map[fileID]string
+	written int64
+}
+
+func writeToArchive(s *createState, root string, isRelativePath bool, logger log.Logger) filepath.WalkFunc {
 	return func(path string, fi os.FileInfo, err error) error {
 		level.Debug(logger).Log("path", path, "root", root) //nolint: errcheck
 
@@ -73,21 +166,12 @@ func writeToArchive(tw *tar.Writer, root string, skipSymlinks bool, written *int
 			return errors.New("no file info")
 		}
 
-		// Create header for Regular files and Directories
-		h, err := tar.FileInfoHeader(fi, fi.Name())
-		if err != nil {
-			return fmt.Errorf("create header for <%s>, %w", path, err)
-		}
-
-		if fi.Mode()&os.ModeSymlink != 0 { // isSymbolic
-			if skipSymlinks {
-				return nil
+		if s.excluded(path) {
+			if fi.IsDir() {
+				return filepath.SkipDir
 			}
 
-			var err error
-			if h, err = createSymlinkHeader(fi, path); err != nil {
-				return fmt.Errorf("create header for symbolic link, %w", err)
-			}
+			return nil
 		}
 
 		var name string
@@ -103,28 +187,188 @@ func writeToArchive(tw *tar.Writer, root string, skipSymlinks bool, written *int
 			return fmt.Errorf("relative name <%s>: <%s>, %w", path, root, err)
 		}
 
-		h.Name = name
+		return s.writeEntry(path, name, fi)
+	}
+}
 
-		if err := tw.WriteHeader(h); err != nil {
-			return fmt.Errorf("write header for <%s>, %w", path, err)
+// excluded reports whether given path matches one of the exclude patterns, relative to the walked source.
+func (s *createState) excluded(path string) bool {
+	rel, err := filepath.Rel(s.src, path)
+	if err != nil || rel == "." {
+		return false
+	}
+
+	for _, pattern := range s.excludes {
+		if ok, _ := filepath.Match(filepath.FromSlash(pattern), rel); ok {
+			return true
 		}
+	}
+
+	return false
+}
+
+// writeEntry writes the file at given path to the archive, with given name.
+func (s *createState) writeEntry(path, name string, fi os.FileInfo) error {
+	// Create header for Regular files and Directories
+	h, err := tar.FileInfoHeader(fi, fi.Name())
+	if err != nil {
+		return fmt.Errorf("create header for <%s>, %w", path, err)
+	}
 
-		if !fi.Mode().IsRegular() {
+	if fi.Mode()&os.ModeSymlink != 0 { // isSymbolic
+		if s.symlinks == common.SymlinkSkip {
 			return nil
 		}
 
-		n, err := writeFileToArchive(tw, path)
-		if err != nil {
-			return fmt.Errorf("write file to archive, %w", err)
+		if s.symlinks.Follows() {
+			followed, err := s.follow(path, name)
+			if err != nil {
+				return fmt.Errorf("follow symbolic link <%s>, %w", path, err)
+			}
+
+			if followed {
+				return nil
+			}
 		}
 
-		*written += n
-		// Alternatives:
-		// *written += h.FileInfo().Size()
-		// *written += fi.Size()
+		if h, err = createSymlinkHeader(fi, path); err != nil {
+			return fmt.Errorf("create header for symbolic link, %w", err)
+		}
+	}
+
+	h.Name = name
+
+	regular := fi.Mode().IsRegular()
+
+	// NOTICE: Further hard links to an already archived file are stored as links, without content.
+	if id, ok := hardLinkID(fi); ok && regular && !s.skipHardLinks {
+		if first, ok := s.links[id]; ok {
+			h.Typeflag = tar.TypeLink
+			h.Linkname = first
+			h.Size = 0
+			regular = false
+		} else {
+			s.links[id] = name
+		}
+	}
+
+	var size int64
+	if regular {
+		size = fi.Size()
+	}
+
+	if err := s.guard.Entry(path, size); err != nil {
+		return err
+	}
+
+	if s.hook != nil && regular {
+		if err := s.hook(path, fi); err != nil {
+			return fmt.Errorf("entry hook for <%s>, %w", path, err)
+		}
+	}
+
+	if !regular {
+		if err := s.tw.WriteHeader(h); err != nil {
+			return fmt.Errorf("write header for <%s>, %w", path, err)
+		}
 
 		return nil
 	}
+
+	n, err := writeFileToArchive(s, h, path)
+	if err != nil {
+		return fmt.Errorf("write file to archive, %w", err)
+	}
+
+	s.written += n
+	// Alternatives:
+	// *written += h.FileInfo().Size()
+	// *written += fi.Size()
+
+	return nil
+}
+
+// follow writes the file or directory that the symbolic link at given path points to, with the name of the link.
+// It returns false if the link has to be preserved instead: it is dangling, it points outside of the root while
+// only links within the root are followed, or following it would loop.
+func (s *createState) follow(path, name string) (bool, error) {
+	target, err := filepath.Abs(path)
+	if err == nil {
+		target, err = filepath.EvalSymlinks(target)
+	}
+
+	if err != nil {
+		level.Debug(s.logger).Log("msg", "preserving dangling symbolic link", "path", path, "err", err) //nolint: errcheck
+		return false, nil
+	}
+
+	if s.symlinks == common.SymlinkFollowWithinRoot && !within(target, realPath(s.root)) &&
+		!within(target, realPath(s.src)) {
+		level.Debug(s.logger).Log("msg", "preserving symbolic link out of root", //nolint: errcheck
+			"path", path, "target", target)
+		return false, nil
+	}
+
+	fi, err := os.Stat(target)
+	if err != nil {
+		return false, fmt.Errorf("stat link target <%s>, %w", target, err)
+	}
+
+	if !fi.IsDir() {
+		return true, s.writeEntry(target, name, fi)
+	}
+
+	// NOTICE: A link to a directory that contains the link, or a link followed to get there, would be walked forever.
+	following := append(s.following, realPath(filepath.Dir(path))) // nolint:gocritic
+	for _, dir := range following {
+		if within(dir, target) {
+			level.Warn(s.logger).Log("msg", "preserving symbolic link that loops", //nolint: errcheck
+				"path", path, "target", target)
+			return false, nil
+		}
+	}
+
+	prev := s.following
+	s.following = append(following, target)
+
+	defer func() { s.following = prev }()
+
+	return true, filepath.Walk(target, func(p string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(target, p)
+		if err != nil {
+			return fmt.Errorf("relative name <%s>: <%s>, %w", p, target, err)
+		}
+
+		return s.writeEntry(p, filepath.Join(name, rel), fi)
+	})
+}
+
+// realPath returns the absolute path of given path with symbolic links resolved, as far as it exists.
+func realPath(path string) string {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return path
+	}
+
+	if resolved, err := filepath.EvalSymlinks(abs); err == nil {
+		return resolved
+	}
+
+	return abs
+}
+
+// within returns true if given path is the parent directory or one of its descendants.
+func within(path, parent string) bool {
+	rel, err := filepath.Rel(parent, path)
+	if err != nil {
+		return false
+	}
+
+	return rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
 }
 
 func relative(parent string, path string) (string, error) {
@@ -159,7 +403,7 @@ func createSymlinkHeader(fi os.FileInfo, path string) (*tar.Header, error) {
 	return h, nil
 }
 
-func writeFileToArchive(tw io.Writer, path string) (n int64, err error) {
+func writeFileToArchive(s *createState, h *tar.Header, path string) (n int64, err error) {
 	f, err := os.Open(path)
 	if err != nil {
 		return 0, fmt.Errorf("open file <%s>, %w", path, err)
@@ -167,7 +411,20 @@ func writeFileToArchive(tw io.Writer, path string) (n int64, err error) {
 
 	defer internal.CloseWithErrCapturef(&err, f, "write file to archive <%s>", path)
 
-	written, err := io.Copy(tw, f)
+	regions, err := dataRegions(f, h.Size)
+	if err != nil {
+		return 0, fmt.Errorf("find data regions of <%s>, %w", path, err)
+	}
+
+	if regions != nil {
+		return writeSparse(s, h, f, regions)
+	}
+
+	if err := s.tw.WriteHeader(h); err != nil {
+		return 0, fmt.Errorf("write header for <%s>, %w", path, err)
+	}
+
+	written, err := io.Copy(s.tw, f)
 	if err != nil {
 		return written, fmt.Errorf("copy the file <%s> data to the tarball, %w", path, err)
 	}
@@ -177,10 +434,38 @@ func writeFileToArchive(tw io.Writer, path string) (n int64, err error) {
 
 // Extract reads content from the given archive reader and restores it to the destination, returns written bytes.
 func (a *Archive) Extract(dst string, r io.Reader) (int64, error) {
-	var (
-		written int64
-		tr      = tar.NewReader(r)
-	)
+	g, own := a.newGuard()
+	if own {
+		r = g.ArchiveReader(r)
+	}
+
+	attrs := newAttributes(a.logger, a.opts.Attributes)
+	p := newPool(a.opts.ExtractConcurrency, attrs.restore)
+
+	written, err := a.extract(dst, tar.NewReader(g.Reader(r)), g, p, attrs)
+
+	n, wErr := p.Wait()
+	written += n
+
+	if err != nil {
+		return written, err
+	}
+
+	if wErr != nil {
+		return written, fmt.Errorf("extract regular file, %w", wErr)
+	}
+
+	if err := attrs.finish(); err != nil {
+		return written, fmt.Errorf("restore directory attributes, %w", err)
+	}
+
+	return written, nil
+}
+
+// extract decodes the archive sequentially, regular files are handed to the given pool and other entries are
+// extracted in place. Returns bytes written in place.
+func (a *Archive) extract(dst string, tr *tar.Reader, g *common.Guard, p *pool, attrs *attributes) (int64, error) {
+	var written int64
 
 	for {
 		h, err := tr.Next()
@@ -188,22 +473,22 @@ func (a *Archive) Extract(dst string, r io.Reader) (int64, error) {
 		switch {
 		case err == io.EOF: // if no more files are found return
 			return written, nil
+		case g.Err() != nil: // decoders do not necessarily wrap errors of the underlying reader
+			return written, g.Err()
 		case err != nil: // return any other error
 			return written, fmt.Errorf("tar reader <%v>, %w", err, ErrArchiveNotReadable)
 		case h == nil: // if the header is nil, skip it
 			continue
 		}
 
-		var target string
-		if dst == h.Name || filepath.IsAbs(h.Name) {
-			target = h.Name
-		} else {
-			name, err := relative(dst, h.Name)
-			if err != nil {
-				return 0, fmt.Errorf("relative name, %w", err)
-			}
+		// NOTICE: Entries are checked against limits before anything is written to the disk.
+		if err := g.Entry(h.Name, h.Size); err != nil {
+			return written, err
+		}
 
-			target = filepath.Join(dst, name)
+		target, err := targetPath(dst, h.Name)
+		if err != nil {
+			return 0, err
 		}
 
 		level.Debug(a.logger).Log("msg", "extracting archive", "path", target)
@@ -212,39 +497,136 @@ func (a *Archive) Extract(dst string, r io.Reader) (int64, error) {
 			return 0, fmt.Errorf("ensure directory <%s>, %w", target, err)
 		}
 
+		// NOTICE: Entries extracted in place must wait for pending writes to the same path.
+		if err := p.Sync(target); err != nil {
+			return written, fmt.Errorf("extract regular file, %w", err)
+		}
+
 		switch h.Typeflag {
 		case tar.TypeDir:
 			if err := extractDir(h, target); err != nil {
 				return written, err
 			}
+		case tar.TypeReg, tar.TypeRegA, tar.TypeGNUSparse:
+			if isSparse(h) {
+				n, err := extractSparse(h, tr, target)
+				written += n
+
+				if err != nil {
+					return written, fmt.Errorf("extract sparse file, %w", err)
+				}
+
+				break
+			}
+
+			if a.opts.ExtractConcurrency > 1 && h.Size <= maxBufferedFileSize {
+				content := make([]byte, h.Size)
+				if _, err := io.ReadFull(tr, content); err != nil {
+					return written, fmt.Errorf("read regular file <%s>, %w", target, err)
+				}
+
+				// NOTICE: Attributes of files written by the pool are restored by the pool.
+				if err := p.Go(h, content, target); err != nil {
+					return written, fmt.Errorf("extract regular file, %w", err)
+				}
+
+				continue
+			}
 
-			continue
-		case tar.TypeReg, tar.TypeRegA, tar.TypeChar, tar.TypeBlock, tar.TypeFifo:
 			n, err := extractRegular(h, tr, target)
 			written += n
 
 			if err != nil {
 				return written, fmt.Errorf("extract regular file, %w", err)
 			}
+		case tar.TypeChar, tar.TypeBlock, tar.TypeFifo:
+			n, err := extractRegular(h, tr, target)
+			written += n
 
-			continue
+			if err != nil {
+				return written, fmt.Errorf("extract regular file, %w", err)
+			}
 		case tar.TypeSymlink:
 			if err := extractSymlink(h, target); err != nil {
 				return written, fmt.Errorf("extract symbolic link, %w", err)
 			}
-
-			continue
 		case tar.TypeLink:
-			if err := extractLink(h, target); err != nil {
-				return written, fmt.Errorf("extract link, %w", err)
+			linkTarget, err := targetPath(dst, h.Linkname)
+			if err != nil {
+				return written, err
 			}
 
-			continue
+			// NOTICE: The link target has to be completely written before it is linked.
+			if err := p.Sync(linkTarget); err != nil {
+				return written, fmt.Errorf("extract regular file, %w", err)
+			}
+
+			if err := extractLink(linkTarget, target); err != nil {
+				return written, fmt.Errorf("extract link, %w", err)
+			}
 		case tar.TypeXGlobalHeader:
 			continue
 		default:
 			return written, fmt.Errorf("extract %s, unknown type flag: %c", target, h.Typeflag)
 		}
+
+		if err := attrs.restore(h, target); err != nil {
+			return written, fmt.Errorf("restore attributes, %w", err)
+		}
+	}
+}
+
+// targetPath returns the path that an entry with given name is extracted to.
+func targetPath(dst, name string) (string, error) {
+	if dst == name || filepath.IsAbs(name) {
+		return name, nil
+	}
+
+	rel, err := relative(dst, name)
+	if err != nil {
+		return "", fmt.Errorf("relative name, %w", err)
+	}
+
+	return filepath.Join(dst, rel), nil
+}
+
+// List reads content from the given archive reader and returns its entries, without extracting them.
+func (a *Archive) List(r io.Reader) ([]common.Entry, error) {
+	g, own := a.newGuard()
+	if own {
+		r = g.ArchiveReader(r)
+	}
+
+	var (
+		entries []common.Entry
+		tr      = tar.NewReader(g.Reader(r))
+	)
+
+	for {
+		h, err := tr.Next()
+
+		switch {
+		case err == io.EOF:
+			return entries, nil
+		case g.Err() != nil:
+			return entries, g.Err()
+		case err != nil:
+			return entries, fmt.Errorf("tar reader <%v>, %w", err, ErrArchiveNotReadable)
+		case h == nil, h.Typeflag == tar.TypeXGlobalHeader:
+			continue
+		}
+
+		if err := g.Entry(h.Name, h.Size); err != nil {
+			return entries, err
+		}
+
+		entries = append(entries, common.Entry{
+			Name:     h.Name,
+			Linkname: h.Linkname,
+			Size:     h.Size,
+			Mode:     h.FileInfo().Mode(),
+			ModTime:  h.ModTime,
+		})
 	}
 }
 
@@ -284,13 +666,13 @@ func extractSymlink(h *tar.Header, target string) error {
 	return nil
 }
 
-func extractLink(h *tar.Header, target string) error {
+func extractLink(linkTarget, target string) error {
 	if err := unlink(target); err != nil {
 		return fmt.Errorf("unlink <%s>, %w", target, err)
 	}
 
-	if err := os.Link(h.Linkname, target); err != nil {
-		return fmt.Errorf("create hard link <%s>, %w", h.Linkname, err)
+	if err := os.Link(linkTarget, target); err != nil {
+		return fmt.Errorf("create hard link <%s>, %w", linkTarget, err)
 	}
 
 	return nil
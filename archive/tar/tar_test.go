@@ -1,6 +1,9 @@
 package tar
 
 import (
+	"archive/tar"
+	"bytes"
+	"fmt"
 	"io"
 	"io/ioutil"
 	"os"
@@ -8,6 +11,7 @@ import (
 	"strings"
 	"testing"
 
+	"github.com/meltwater/drone-cache/archive/common"
 	"github.com/meltwater/drone-cache/test"
 
 	"github.com/go-kit/kit/log"
@@ -307,6 +311,292 @@ func TestExtract(t *testing.T) {
 	}
 }
 
+func TestExtractParallel(t *testing.T) {
+	dst := filepath.Join(testRootExtracted, "parallel")
+	t.Cleanup(func() { os.RemoveAll(testRoot) })
+
+	files := map[string][]byte{}
+
+	var (
+		buf     bytes.Buffer
+		written int64
+		tw      = tar.NewWriter(&buf)
+	)
+
+	add := func(h *tar.Header, content []byte) {
+		test.Ok(t, tw.WriteHeader(h))
+		_, err := tw.Write(content)
+		test.Ok(t, err)
+
+		written += int64(len(content))
+	}
+
+	add(&tar.Header{Typeflag: tar.TypeDir, Name: "parallel/", Mode: 0755}, nil)
+
+	for i := 0; i < 64; i++ {
+		name := fmt.Sprintf("parallel/dir_%d/file_%d", i%16, i)
+		files[name] = []byte(strings.Repeat(name, i))
+		add(&tar.Header{Typeflag: tar.TypeReg, Name: name, Mode: 0644, Size: int64(len(files[name]))}, files[name])
+	}
+
+	// Later entries with the same name must win.
+	add(&tar.Header{Typeflag: tar.TypeReg, Name: "parallel/dup", Mode: 0644, Size: 4}, []byte("one\n"))
+	add(&tar.Header{Typeflag: tar.TypeReg, Name: "parallel/dup", Mode: 0644, Size: 4}, []byte("two\n"))
+	files["parallel/dup"] = []byte("two\n")
+
+	large := bytes.Repeat([]byte("l"), maxBufferedFileSize+1)
+	add(&tar.Header{Typeflag: tar.TypeReg, Name: "parallel/large", Mode: 0644, Size: int64(len(large))}, large)
+	files["parallel/large"] = large
+
+	add(&tar.Header{Typeflag: tar.TypeSymlink, Name: "parallel/link", Linkname: "dup"}, nil)
+	test.Ok(t, tw.Close())
+
+	n, err := NewWithOptions(log.NewNopLogger(), testRootMounted, common.Options{ExtractConcurrency: 8}).Extract(dst, bytes.NewReader(buf.Bytes()))
+	test.Ok(t, err)
+	test.Equals(t, written, n)
+
+	for name, content := range files {
+		got, err := ioutil.ReadFile(filepath.Join(dst, name))
+		test.Ok(t, err)
+		test.Equals(t, content, got)
+	}
+
+	got, err := ioutil.ReadFile(filepath.Join(dst, "parallel/link"))
+	test.Ok(t, err)
+	test.Equals(t, []byte("two\n"), got)
+}
+
+func TestHardLinks(t *testing.T) {
+	src := filepath.Join(testRootMounted, "links")
+	dst := filepath.Join(testRootExtracted, "links")
+
+	test.Ok(t, os.MkdirAll(src, 0755))
+	t.Cleanup(func() { os.RemoveAll(testRoot) })
+
+	content := []byte(strings.Repeat("linked\n", 1024))
+	test.Ok(t, ioutil.WriteFile(filepath.Join(src, "a"), content, 0644))
+	test.Ok(t, os.Link(filepath.Join(src, "a"), filepath.Join(src, "b")))
+
+	a := New(log.NewNopLogger(), testRootMounted, false)
+
+	var buf bytes.Buffer
+	written, err := a.Create([]string{src}, &buf, true)
+	test.Ok(t, err)
+	test.Equals(t, int64(len(content)), written)
+
+	entries, err := a.List(bytes.NewReader(buf.Bytes()))
+	test.Ok(t, err)
+	test.Equals(t, 3, len(entries))
+	test.Equals(t, filepath.Join(src, "a"), entries[2].Linkname)
+
+	_, err = a.Extract(dst, bytes.NewReader(buf.Bytes()))
+	test.Ok(t, err)
+
+	fa, err := os.Stat(filepath.Join(dst, "mounted", "links", "a"))
+	test.Ok(t, err)
+
+	fb, err := os.Stat(filepath.Join(dst, "mounted", "links", "b"))
+	test.Ok(t, err)
+
+	test.Assert(t, os.SameFile(fa, fb), "extracted files must be hard links of each other")
+
+	got, err := ioutil.ReadFile(filepath.Join(dst, "mounted", "links", "b"))
+	test.Ok(t, err)
+	test.Equals(t, content, got)
+}
+
+func TestParallelHardLinks(t *testing.T) {
+	dst := filepath.Join(testRootExtracted, "parallel-links")
+	t.Cleanup(func() { os.RemoveAll(testRoot) })
+
+	var buf bytes.Buffer
+
+	tw := tar.NewWriter(&buf)
+	files := map[string][]byte{}
+
+	// Every link directly follows its target, while the target is most likely still being written by the pool.
+	for i := 0; i < 256; i++ {
+		name := fmt.Sprintf("links/file_%d", i)
+		files[name] = []byte(strings.Repeat(name, 1024))
+		test.Ok(t, tw.WriteHeader(&tar.Header{Typeflag: tar.TypeReg, Name: name, Mode: 0644, Size: int64(len(files[name]))}))
+		_, err := tw.Write(files[name])
+		test.Ok(t, err)
+
+		link := fmt.Sprintf("links/link_%d", i)
+		files[link] = files[name]
+		test.Ok(t, tw.WriteHeader(&tar.Header{Typeflag: tar.TypeLink, Name: link, Linkname: name}))
+	}
+
+	test.Ok(t, tw.Close())
+
+	a := NewWithOptions(log.NewNopLogger(), testRootMounted, common.Options{ExtractConcurrency: 16})
+	_, err := a.Extract(dst, bytes.NewReader(buf.Bytes()))
+	test.Ok(t, err)
+
+	for name, content := range files {
+		got, err := ioutil.ReadFile(filepath.Join(dst, name))
+		test.Ok(t, err)
+		test.Equals(t, content, got)
+	}
+}
+
+func TestExcludes(t *testing.T) {
+	src := filepath.Join(testRootMounted, "excludes")
+	t.Cleanup(func() { os.RemoveAll(testRoot) })
+
+	for _, dir := range []string{"target/debug/incremental", "target/debug/deps", "incremental"} {
+		test.Ok(t, os.MkdirAll(filepath.Join(src, dir), 0755))
+		test.Ok(t, ioutil.WriteFile(filepath.Join(src, dir, "artifact"), []byte(dir), 0644))
+	}
+
+	a := NewWithOptions(log.NewNopLogger(), testRootMounted, common.Options{Excludes: []string{"target/*/incremental"}})
+
+	var buf bytes.Buffer
+	_, err := a.Create([]string{src}, &buf, true)
+	test.Ok(t, err)
+
+	entries, err := a.List(bytes.NewReader(buf.Bytes()))
+	test.Ok(t, err)
+
+	var names []string
+	for _, e := range entries {
+		names = append(names, filepath.ToSlash(strings.TrimPrefix(e.Name, src)))
+	}
+
+	test.Equals(t, []string{"", "/incremental", "/incremental/artifact", "/target", "/target/debug",
+		"/target/debug/deps", "/target/debug/deps/artifact"}, names)
+}
+
+func TestSymlinkPolicy(t *testing.T) {
+	src := filepath.Join(testRootMounted, "symlinks")
+	outside := filepath.Join(testRoot, "outside")
+
+	test.Ok(t, os.MkdirAll(filepath.Join(src, "dir"), 0755))
+	test.Ok(t, os.MkdirAll(outside, 0755))
+	t.Cleanup(func() { os.RemoveAll(testRoot) })
+
+	test.Ok(t, ioutil.WriteFile(filepath.Join(src, "dir", "file"), []byte("inside"), 0644))
+	test.Ok(t, ioutil.WriteFile(filepath.Join(outside, "file"), []byte("outside"), 0644))
+	test.Ok(t, os.Symlink(filepath.Join("dir", "file"), filepath.Join(src, "file-link")))
+	test.Ok(t, os.Symlink("dir", filepath.Join(src, "dir-link")))
+	test.Ok(t, os.Symlink("..", filepath.Join(src, "dir", "loop")))
+	test.Ok(t, os.Symlink(filepath.Join("..", "..", "outside", "file"), filepath.Join(src, "out-link")))
+
+	const (
+		link = "link"
+		dir  = "dir"
+		file = "file"
+	)
+
+	for _, tc := range []struct {
+		policy common.SymlinkPolicy
+		want   map[string]string
+	}{
+		{
+			policy: common.SymlinkPreserve,
+			want:   map[string]string{"file-link": link, "dir-link": link, "dir/loop": link, "out-link": link},
+		},
+		{
+			policy: common.SymlinkSkip,
+			want:   map[string]string{},
+		},
+		{
+			policy: common.SymlinkFollow,
+			want: map[string]string{"file-link": file, "dir-link": dir, "dir-link/file": file,
+				"dir-link/loop": link, "dir/loop": link, "out-link": file},
+		},
+		{
+			policy: common.SymlinkFollowWithinRoot,
+			want: map[string]string{"file-link": file, "dir-link": dir, "dir-link/file": file,
+				"dir-link/loop": link, "dir/loop": link, "out-link": link},
+		},
+	} {
+		tc := tc
+		t.Run(string(tc.policy), func(t *testing.T) {
+			a := NewWithOptions(log.NewNopLogger(), testRootMounted, common.Options{Symlinks: tc.policy})
+
+			var buf bytes.Buffer
+			_, err := a.Create([]string{src}, &buf, true)
+			test.Ok(t, err)
+
+			entries, err := a.List(bytes.NewReader(buf.Bytes()))
+			test.Ok(t, err)
+
+			got := map[string]string{}
+			for _, e := range entries {
+				name, err := filepath.Rel(src, e.Name)
+				test.Ok(t, err)
+
+				switch {
+				case name == "." || name == "dir" || name == "dir/file":
+					continue
+				case e.Mode&os.ModeSymlink != 0:
+					got[name] = link
+				case e.Mode.IsDir():
+					got[name] = dir
+				default:
+					got[name] = file
+				}
+			}
+
+			test.Equals(t, tc.want, got)
+
+			dst := filepath.Join(testRootExtracted, string(tc.policy))
+			_, err = a.Extract(dst, bytes.NewReader(buf.Bytes()))
+			test.Ok(t, err)
+
+			if tc.policy == common.SymlinkFollow {
+				content, err := ioutil.ReadFile(filepath.Join(dst, "mounted", "symlinks", "out-link"))
+				test.Ok(t, err)
+				test.Equals(t, "outside", string(content))
+			}
+		})
+	}
+}
+
+func BenchmarkExtract(b *testing.B) {
+	var (
+		buf  bytes.Buffer
+		size int64
+		tw   = tar.NewWriter(&buf)
+	)
+
+	// Many small files, like node_modules.
+	content := []byte(strings.Repeat("module.exports = require('./lib');\n", 32))
+	for i := 0; i < 5000; i++ {
+		h := &tar.Header{Typeflag: tar.TypeReg, Name: fmt.Sprintf("bench/pkg_%d/file_%d.js", i%500, i), Mode: 0644,
+			Size: int64(len(content))}
+		test.Ok(b, tw.WriteHeader(h))
+		_, err := tw.Write(content)
+		test.Ok(b, err)
+
+		size += h.Size
+	}
+
+	test.Ok(b, tw.Close())
+
+	for _, concurrency := range []int{1, 4, 16, 64} {
+		concurrency := concurrency
+		b.Run(fmt.Sprintf("concurrency=%d", concurrency), func(b *testing.B) {
+			a := NewWithOptions(log.NewNopLogger(), testRootMounted, common.Options{ExtractConcurrency: concurrency})
+
+			b.SetBytes(size)
+
+			for i := 0; i < b.N; i++ {
+				b.StopTimer()
+				test.Ok(b, os.RemoveAll(testRootExtracted))
+				b.StartTimer()
+
+				_, err := a.Extract(testRootExtracted, bytes.NewReader(buf.Bytes()))
+				test.Ok(b, err)
+			}
+
+			b.StopTimer()
+			test.Ok(b, os.RemoveAll(testRoot))
+		})
+	}
+}
+
 // Helpers
 
 func create(a *Archive, srcs []string, dst string) (int64, error) {
@@ -0,0 +1,114 @@
+package xz
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+
+	"github.com/go-kit/log"
+	"github.com/ulikunitz/xz"
+
+	"github.com/meltwater/drone-cache/archive/common"
+	"github.com/meltwater/drone-cache/archive/tar"
+	"github.com/meltwater/drone-cache/internal"
+)
+
+const defaultPreset = 6
+
+// dictionaryCapacities holds the dictionary sizes of the xz presets 0 to 9.
+var dictionaryCapacities = []int{
+	256 << 10, 1 << 20, 2 << 20, 4 << 20, 4 << 20, 8 << 20, 8 << 20, 16 << 20, 32 << 20, 64 << 20,
+}
+
+// Archive implements archive for xz.
+type Archive struct {
+	logger log.Logger
+
+	root             string
+	compressionLevel int
+	opts             common.Options
+}
+
+// New creates an archive that uses the .tar.xz file format.
+func New(logger log.Logger, root string, skipSymlinks bool, compressionLevel int) *Archive {
+	return NewWithOptions(logger, root, compressionLevel, common.Options{Symlinks: common.SkipSymlinks(skipSymlinks)})
+}
+
+// NewWithOptions creates an archive that uses the .tar.xz file format with given options.
+// Options apply to the tar stream, see tar.NewWithOptions.
+func NewWithOptions(logger log.Logger, root string, compressionLevel int, opts common.Options) *Archive {
+	return &Archive{logger: logger, root: root, compressionLevel: compressionLevel, opts: opts}
+}
+
+// Create writes content of the given source to an archive, returns written bytes.
+func (a *Archive) Create(srcs []string, w io.Writer, isRelativePath bool) (written int64, err error) {
+	g := common.NewGuard(a.opts.Limits)
+	defer g.Capture(&err)
+
+	w = g.ArchiveWriter(w)
+
+	xw, err := xz.WriterConfig{DictCap: dictionaryCapacity(a.compressionLevel)}.NewWriter(w)
+	if err != nil {
+		return 0, fmt.Errorf("xz create archive writer, %w", err)
+	}
+
+	defer internal.CloseWithErrLogf(a.logger, xw, "xz writer")
+
+	wBytes, err := a.newTar(g).Create(srcs, xw, isRelativePath)
+	if err != nil {
+		return 0, fmt.Errorf("xz create archive, %w", err)
+	}
+
+	return wBytes, nil
+}
+
+// Extract reads content from the given archive reader and restores it to the destination, returns written bytes.
+func (a *Archive) Extract(dst string, r io.Reader) (int64, error) {
+	g := common.NewGuard(a.opts.Limits)
+
+	xr, err := xz.NewReader(bufio.NewReader(g.ArchiveReader(r)))
+	if err != nil {
+		return 0, fmt.Errorf("xz create extract archive reader, %w", err)
+	}
+
+	eBytes, err := a.newTar(g).Extract(dst, xr)
+	if err != nil {
+		return 0, fmt.Errorf("xz extract archive, %w", err)
+	}
+
+	return eBytes, nil
+}
+
+// List reads content from the given archive reader and returns its entries, without extracting them.
+func (a *Archive) List(r io.Reader) ([]common.Entry, error) {
+	g := common.NewGuard(a.opts.Limits)
+
+	xr, err := xz.NewReader(bufio.NewReader(g.ArchiveReader(r)))
+	if err != nil {
+		return nil, fmt.Errorf("xz create list archive reader, %w", err)
+	}
+
+	entries, err := a.newTar(g).List(xr)
+	if err != nil {
+		return nil, fmt.Errorf("xz list archive, %w", err)
+	}
+
+	return entries, nil
+}
+
+func (a *Archive) newTar(g *common.Guard) *tar.Archive {
+	return tar.NewWithOptions(a.logger, a.root, a.opts).WithGuard(g)
+}
+
+// dictionaryCapacity maps given flate style level to the dictionary size of the matching xz preset,
+// larger dictionaries compress better at the expense of memory.
+func dictionaryCapacity(level int) int {
+	switch {
+	case level < 0:
+		return dictionaryCapacities[defaultPreset]
+	case level >= len(dictionaryCapacities):
+		return dictionaryCapacities[len(dictionaryCapacities)-1]
+	default:
+		return dictionaryCapacities[level]
+	}
+}
@@ -0,0 +1,402 @@
+package zip
+
+import (
+	stdtar "archive/tar"
+	"archive/zip"
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/klauspost/compress/flate"
+	"github.com/klauspost/compress/zstd"
+
+	"github.com/meltwater/drone-cache/archive/common"
+	"github.com/meltwater/drone-cache/archive/tar"
+	"github.com/meltwater/drone-cache/internal"
+
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+)
+
+// maxLinkSize is the maximum size of the target of a symbolic link entry.
+const maxLinkSize = 4096
+
+// ErrUnknownMethod means that given compression method is not supported.
+var ErrUnknownMethod = errors.New("unknown zip compression method")
+
+// Method is the compression method of zip entries.
+type Method string
+
+const (
+	// Deflate compresses entries with deflate, it is supported by every zip tool.
+	Deflate Method = "deflate"
+	// Zstd compresses entries with zstd, using the WinZip method id.
+	Zstd Method = "zstd"
+	// Store stores entries without compression.
+	Store Method = "store"
+)
+
+// ParseMethod parses given compression method, an empty value is Deflate.
+func ParseMethod(s string) (Method, error) {
+	switch m := Method(s); m {
+	case "":
+		return Deflate, nil
+	case Deflate, Zstd, Store:
+		return m, nil
+	default:
+		return "", fmt.Errorf("<%s>, (%s, %s, %s), %w", s, Deflate, Zstd, Store, ErrUnknownMethod)
+	}
+}
+
+// Archive implements archive for zip.
+type Archive struct {
+	logger log.Logger
+
+	root             string
+	compressionLevel int
+	method           Method
+	opts             common.Options
+}
+
+// New creates an archive that uses the .zip file format, entries are compressed with deflate.
+func New(logger log.Logger, root string, skipSymlinks bool, compressionLevel int) *Archive {
+	return NewWithOptions(logger, root, compressionLevel, Deflate,
+		common.Options{Symlinks: common.SkipSymlinks(skipSymlinks)})
+}
+
+// NewWithOptions creates an archive that uses the .zip file format with given options.
+// Entries are compressed with given method, and have forward slash separated names on every platform.
+// If Adaptive is set, incompressible files are stored without compression.
+// The rest of the options apply to the tar stream the entries are converted from, see tar.NewWithOptions.
+// Zip archives do not store owners and hard links, every link is archived as a regular file.
+func NewWithOptions(logger log.Logger, root string, compressionLevel int, method Method,
+	opts common.Options) *Archive {
+	return &Archive{logger: logger, root: root, compressionLevel: compressionLevel, method: method, opts: opts}
+}
+
+// Create writes content of the given source to an archive, returns written bytes.
+// If isRelativePath is true, it clones using the path, else it clones using a path
+// combining archive's root with the path.
+func (a *Archive) Create(srcs []string, w io.Writer, isRelativePath bool) (written int64, err error) {
+	g := common.NewGuard(a.opts.Limits)
+	defer g.Capture(&err)
+
+	zw := zip.NewWriter(g.ArchiveWriter(w))
+	a.registerCompressors(zw)
+
+	// NOTICE: Sources are walked as a tar stream, that is converted to zip entries as it is written.
+	pr, pw := io.Pipe()
+	done := make(chan error, 1)
+
+	go func() {
+		var err error
+
+		written, err = a.newTar(g).WithoutHardLinks().Create(srcs, pw, isRelativePath)
+		pw.CloseWithError(err) // nolint:errcheck // always returns nil.
+		done <- err
+	}()
+
+	cErr := a.fromTar(zw, stdtar.NewReader(pr))
+	if cErr == nil {
+		// NOTICE: Drain the rest of the stream, so that the tar writer is never blocked.
+		_, cErr = io.Copy(io.Discard, pr)
+	}
+
+	pr.CloseWithError(cErr) // nolint:errcheck // always returns nil.
+
+	if err := <-done; err != nil {
+		return written, fmt.Errorf("zip create archive, %w", err)
+	}
+
+	if cErr != nil {
+		return written, fmt.Errorf("zip create archive, %w", cErr)
+	}
+
+	// NOTICE: The central directory is written on close, archives of failed operations are abandoned without it.
+	if err := zw.Close(); err != nil {
+		return written, fmt.Errorf("zip write central directory, %w", err)
+	}
+
+	return written, nil
+}
+
+// fromTar writes the entries of given tar stream to given zip writer.
+func (a *Archive) fromTar(zw *zip.Writer, tr *stdtar.Reader) error {
+	sample := common.NewSampleReader(nil)
+
+	for {
+		h, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+
+		if err != nil {
+			return fmt.Errorf("read tar stream, %w", err)
+		}
+
+		fh := &zip.FileHeader{Name: filepath.ToSlash(h.Name), Modified: h.ModTime, Method: zip.Store}
+		fh.SetMode(h.FileInfo().Mode())
+
+		switch h.Typeflag {
+		case stdtar.TypeDir:
+			fh.Name = strings.TrimSuffix(fh.Name, "/") + "/"
+
+			if _, err := zw.CreateHeader(fh); err != nil {
+				return fmt.Errorf("write directory <%s>, %w", fh.Name, err)
+			}
+		case stdtar.TypeSymlink:
+			// NOTICE: Symbolic links are stored with their target as content, like Info-ZIP does.
+			fw, err := zw.CreateHeader(fh)
+			if err != nil {
+				return fmt.Errorf("write symbolic link <%s>, %w", fh.Name, err)
+			}
+
+			if _, err := io.WriteString(fw, h.Linkname); err != nil {
+				return fmt.Errorf("write symbolic link <%s>, %w", fh.Name, err)
+			}
+		case stdtar.TypeReg, stdtar.TypeRegA, stdtar.TypeGNUSparse:
+			sample.Reset(tr)
+
+			fh.Method = a.entryMethod(fh.Name, h.Size, sample)
+			fh.UncompressedSize64 = uint64(h.Size)
+
+			fw, err := zw.CreateHeader(fh)
+			if err != nil {
+				return fmt.Errorf("write file <%s>, %w", fh.Name, err)
+			}
+
+			if _, err := io.Copy(fw, sample); err != nil {
+				return fmt.Errorf("write file <%s>, %w", fh.Name, err)
+			}
+		default:
+			level.Warn(a.logger).Log("msg", "skipping entry, zip archives only store files, directories and links", //nolint: errcheck
+				"name", h.Name, "type", string(h.Typeflag))
+		}
+	}
+}
+
+func (a *Archive) entryMethod(name string, size int64, sample *bufio.Reader) uint16 {
+	if a.method == Store || (a.opts.Adaptive && common.IncompressibleStream(name, size, sample)) {
+		return zip.Store
+	}
+
+	if a.method == Zstd {
+		return zstd.ZipMethodWinZip
+	}
+
+	return zip.Deflate
+}
+
+func (a *Archive) registerCompressors(zw *zip.Writer) {
+	zw.RegisterCompressor(zip.Deflate, func(w io.Writer) (io.WriteCloser, error) {
+		return flate.NewWriter(w, a.compressionLevel)
+	})
+
+	level := zstd.SpeedDefault
+	if a.compressionLevel != -1 {
+		level = zstd.EncoderLevelFromZstd(a.compressionLevel)
+	}
+
+	zw.RegisterCompressor(zstd.ZipMethodWinZip, zstd.ZipCompressor(zstd.WithEncoderLevel(level),
+		zstd.WithEncoderConcurrency(1)))
+}
+
+// Extract reads content from the given archive reader and restores it to the destination, returns written bytes.
+func (a *Archive) Extract(dst string, r io.Reader) (int64, error) {
+	g := common.NewGuard(a.opts.Limits)
+
+	zr, cleanup, err := a.open(g.ArchiveReader(r))
+	if err != nil {
+		return 0, fmt.Errorf("zip create extract archive reader, %w", err)
+	}
+
+	defer cleanup()
+
+	// NOTICE: Entries are converted to a tar stream, that is extracted as it is read.
+	pr, pw := io.Pipe()
+	done := make(chan error, 1)
+
+	go func() {
+		err := toTar(zr, pw)
+		pw.CloseWithError(err) // nolint:errcheck // always returns nil.
+		done <- err
+	}()
+
+	written, err := a.newTar(g).Extract(dst, pr)
+	if err == nil {
+		// NOTICE: Drain the rest of the stream, so that the tar writer is never blocked.
+		_, err = io.Copy(io.Discard, pr)
+	}
+
+	pr.CloseWithError(err) // nolint:errcheck // always returns nil.
+
+	if cErr := <-done; cErr != nil && err == nil {
+		err = cErr
+	}
+
+	if err != nil {
+		return written, fmt.Errorf("zip extract archive, %w", err)
+	}
+
+	return written, nil
+}
+
+// toTar writes the entries of given zip archive to given writer as a tar stream.
+func toTar(zr *zip.Reader, w io.Writer) (err error) {
+	tw := stdtar.NewWriter(w)
+	defer internal.CloseWithErrCapturef(&err, tw, "tar writer")
+
+	for _, f := range zr.File {
+		fi := f.FileInfo()
+		name := normalize(f.Name)
+
+		var link string
+
+		if fi.Mode()&os.ModeSymlink != 0 {
+			if link, err = readLink(f); err != nil {
+				return err
+			}
+		}
+
+		h, err := stdtar.FileInfoHeader(fi, link)
+		if err != nil {
+			return fmt.Errorf("create header for <%s>, %w", name, err)
+		}
+
+		h.Name = name
+		h.ModTime = f.Modified
+
+		if err := tw.WriteHeader(h); err != nil {
+			return fmt.Errorf("write header for <%s>, %w", name, err)
+		}
+
+		if h.Typeflag != stdtar.TypeReg {
+			continue
+		}
+
+		if err := copyFile(tw, f); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func copyFile(w io.Writer, f *zip.File) (err error) {
+	rc, err := f.Open()
+	if err != nil {
+		return fmt.Errorf("open <%s>, %w", f.Name, err)
+	}
+
+	defer internal.CloseWithErrCapturef(&err, rc, "zip entry <%s>", f.Name)
+
+	if _, err := io.Copy(w, rc); err != nil {
+		return fmt.Errorf("read <%s>, %w", f.Name, err)
+	}
+
+	return nil
+}
+
+func readLink(f *zip.File) (_ string, err error) {
+	rc, err := f.Open()
+	if err != nil {
+		return "", fmt.Errorf("open symbolic link <%s>, %w", f.Name, err)
+	}
+
+	defer internal.CloseWithErrCapturef(&err, rc, "zip entry <%s>", f.Name)
+
+	b, err := io.ReadAll(io.LimitReader(rc, maxLinkSize))
+	if err != nil {
+		return "", fmt.Errorf("read symbolic link <%s>, %w", f.Name, err)
+	}
+
+	return string(b), nil
+}
+
+// List reads content from the given archive reader and returns its entries, without extracting them.
+// Entries are read from the central directory of the archive, without decompressing their content.
+func (a *Archive) List(r io.Reader) ([]common.Entry, error) {
+	g := common.NewGuard(a.opts.Limits)
+
+	zr, cleanup, err := a.open(g.ArchiveReader(r))
+	if err != nil {
+		return nil, fmt.Errorf("zip create list archive reader, %w", err)
+	}
+
+	defer cleanup()
+
+	entries := make([]common.Entry, 0, len(zr.File))
+
+	for _, f := range zr.File {
+		name := normalize(f.Name)
+		size := int64(f.UncompressedSize64)
+
+		if err := g.Entry(name, size); err != nil {
+			return entries, err
+		}
+
+		e := common.Entry{Name: name, Size: size, Mode: f.Mode(), ModTime: f.Modified}
+
+		if e.Mode&os.ModeSymlink != 0 {
+			if e.Linkname, err = readLink(f); err != nil {
+				return entries, err
+			}
+		}
+
+		entries = append(entries, e)
+	}
+
+	return entries, nil
+}
+
+// open returns a reader of given archive. Zip archives are read from their central directory at their end,
+// so the archive is buffered to a temporary file first. The returned function removes the file.
+func (a *Archive) open(r io.Reader) (*zip.Reader, func(), error) {
+	f, err := os.CreateTemp("", "drone-cache-*.zip")
+	if err != nil {
+		return nil, nil, fmt.Errorf("create temporary file, %w", err)
+	}
+
+	cleanup := func() {
+		internal.CloseWithErrLogf(a.logger, f, "zip temporary file")
+
+		if err := os.Remove(f.Name()); err != nil {
+			level.Error(a.logger).Log("msg", "remove zip temporary file", "err", err) //nolint: errcheck
+		}
+	}
+
+	size, err := io.Copy(f, r)
+	if err != nil {
+		cleanup()
+		return nil, nil, fmt.Errorf("buffer archive, %w", err)
+	}
+
+	zr, err := zip.NewReader(f, size)
+	if err != nil && !errors.Is(err, zip.ErrInsecurePath) {
+		cleanup()
+		return nil, nil, fmt.Errorf("read archive, %w", err)
+	}
+
+	zr.RegisterDecompressor(zstd.ZipMethodWinZip, zstd.ZipDecompressor())
+	zr.RegisterDecompressor(zstd.ZipMethodPKWare, zstd.ZipDecompressor())
+
+	return zr, cleanup, nil
+}
+
+func (a *Archive) newTar(g *common.Guard) *tar.Archive {
+	// NOTICE: Zip archives do not store owners, entries would be owned by root otherwise.
+	opts := a.opts
+	opts.Attributes.PreserveOwner = false
+
+	return tar.NewWithOptions(a.logger, a.root, opts).WithGuard(g)
+}
+
+// normalize returns given entry name with forward slashes, archives written on Windows by other tools may use
+// backslashes.
+func normalize(name string) string {
+	return strings.ReplaceAll(name, `\`, "/")
+}
@@ -0,0 +1,146 @@
+package zip
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/flate"
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/go-kit/kit/log"
+
+	"github.com/meltwater/drone-cache/archive/common"
+	"github.com/meltwater/drone-cache/test"
+)
+
+var (
+	testRoot          = "testdata"
+	testRootMounted   = "testdata/mounted"
+	testRootExtracted = "testdata/extracted"
+)
+
+func TestCreateAndExtract(t *testing.T) {
+	test.Ok(t, os.MkdirAll(testRootMounted, 0755))
+	test.Ok(t, os.MkdirAll(testRootExtracted, 0755))
+	t.Cleanup(func() { os.RemoveAll(testRoot) })
+
+	src, srcClean := test.CreateTempDir(t, "zip_create", testRootMounted)
+	t.Cleanup(srcClean)
+
+	for i := 0; i < 3; i++ {
+		content := []byte(strings.Repeat(fmt.Sprintf("content %d\n", i), 1024))
+		test.Ok(t, os.WriteFile(filepath.Join(src, fmt.Sprintf("file_%d", i)), content, 0644))
+	}
+
+	random := make([]byte, common.AdaptiveThreshold)
+	rand.New(rand.NewSource(1)).Read(random) // nolint:errcheck,gosec
+	test.Ok(t, os.WriteFile(filepath.Join(src, "random"), random, 0644))
+	test.Ok(t, os.Symlink("file_0", filepath.Join(src, "link")))
+
+	for _, tc := range []struct {
+		method   Method
+		adaptive bool
+		stored   int // number of stored files
+	}{
+		{method: Deflate},
+		{method: Zstd},
+		{method: Store, stored: 4},
+		{method: Deflate, adaptive: true, stored: 1},
+	} {
+		tc := tc
+		t.Run(fmt.Sprintf("%s/adaptive=%v", tc.method, tc.adaptive), func(t *testing.T) {
+			a := NewWithOptions(log.NewNopLogger(), testRootMounted, flate.DefaultCompression, tc.method,
+				common.Options{Adaptive: tc.adaptive})
+
+			var buf bytes.Buffer
+			written, err := a.Create([]string{src}, &buf, false)
+			test.Ok(t, err)
+			test.Equals(t, int64(3*10*1024+len(random)), written)
+
+			zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+			test.Ok(t, err)
+
+			stored := 0
+			for _, f := range zr.File {
+				test.Assert(t, !strings.Contains(f.Name, `\`), "entry names must use forward slashes, got %s", f.Name)
+
+				if f.Mode().IsRegular() && f.Method == zip.Store {
+					stored++
+				}
+			}
+
+			test.Equals(t, tc.stored, stored)
+
+			entries, err := a.List(bytes.NewReader(buf.Bytes()))
+			test.Ok(t, err)
+			test.Equals(t, 6, len(entries)) // directory, 4 files and a link
+
+			for _, e := range entries {
+				if e.Mode&os.ModeSymlink != 0 {
+					test.Equals(t, "file_0", e.Linkname)
+				}
+			}
+
+			dst, dstClean := test.CreateTempDir(t, "zip_extract", testRootExtracted)
+			t.Cleanup(dstClean)
+
+			extracted, err := a.Extract(dst, bytes.NewReader(buf.Bytes()))
+			test.Ok(t, err)
+			test.Equals(t, written, extracted)
+
+			for _, name := range []string{"file_0", "file_1", "file_2", "random", "link"} {
+				want, err := os.ReadFile(filepath.Join(src, name))
+				test.Ok(t, err)
+
+				got, err := os.ReadFile(filepath.Join(dst, filepath.Base(src), name))
+				test.Ok(t, err)
+				test.Assert(t, bytes.Equal(want, got), "content of <%s> must be restored", name)
+			}
+
+			link, err := os.Readlink(filepath.Join(dst, filepath.Base(src), "link"))
+			test.Ok(t, err)
+			test.Equals(t, "file_0", link)
+		})
+	}
+}
+
+func TestExtractNormalizesNames(t *testing.T) {
+	test.Ok(t, os.MkdirAll(testRootExtracted, 0755))
+	t.Cleanup(func() { os.RemoveAll(testRoot) })
+
+	// Archives written on Windows by other tools may use backslashes.
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+
+	fw, err := zw.Create(`windows\dir\file`)
+	test.Ok(t, err)
+
+	_, err = fw.Write([]byte("windows"))
+	test.Ok(t, err)
+	test.Ok(t, zw.Close())
+
+	a := New(log.NewNopLogger(), testRootMounted, false, flate.DefaultCompression)
+
+	entries, err := a.List(bytes.NewReader(buf.Bytes()))
+	test.Ok(t, err)
+	test.Equals(t, 1, len(entries))
+	test.Equals(t, "windows/dir/file", entries[0].Name)
+
+	_, err = a.Extract(testRootExtracted, bytes.NewReader(buf.Bytes()))
+	test.Ok(t, err)
+
+	got, err := os.ReadFile(filepath.Join(testRootExtracted, "windows", "dir", "file"))
+	test.Ok(t, err)
+	test.Equals(t, "windows", string(got))
+}
+
+func TestExtractInvalid(t *testing.T) {
+	a := New(log.NewNopLogger(), testRootMounted, false, flate.DefaultCompression)
+
+	_, err := a.Extract(testRootExtracted, strings.NewReader("not a zip archive"))
+	test.NotOk(t, err)
+}
@@ -0,0 +1,87 @@
+package zstd
+
+import (
+	"io"
+)
+
+// rawBlockSize is the maximum size of a zstd block.
+const rawBlockSize = 128 << 10
+
+// rawFrameHeader holds the magic number, a frame header descriptor without content size, checksum and dictionary,
+// and a window descriptor of 128 KiB, that fits a single block.
+var rawFrameHeader = []byte{0x28, 0xb5, 0x2f, 0xfd, 0x00, 0x38}
+
+// rawWriter writes zstd frames of raw blocks, which store data as is, as defined by RFC 8878.
+// NOTICE: The zstd encoder has no level that skips match finding, even without entropy coding.
+// Incompressible data is written without running the encoder at all.
+type rawWriter struct {
+	w io.Writer
+
+	buf     []byte
+	started bool
+}
+
+func newRawWriter(w io.Writer) *rawWriter {
+	return &rawWriter{w: w, buf: make([]byte, 0, rawBlockSize)}
+}
+
+// Reset discards the state of the writer and starts a new frame written to w.
+func (r *rawWriter) Reset(w io.Writer) {
+	r.w = w
+	r.buf = r.buf[:0]
+	r.started = false
+}
+
+// Write buffers given data, and writes a block for every full buffer.
+func (r *rawWriter) Write(p []byte) (int, error) {
+	var written int
+
+	for len(p) > 0 {
+		// NOTICE: Full blocks are written once more data arrives, so that the last one can be marked on Close.
+		if len(r.buf) == rawBlockSize {
+			if err := r.writeBlock(false); err != nil {
+				return written, err
+			}
+		}
+
+		n := copy(r.buf[len(r.buf):rawBlockSize], p)
+		r.buf = r.buf[:len(r.buf)+n]
+		p = p[n:]
+		written += n
+	}
+
+	return written, nil
+}
+
+// Close writes the last block and finishes the frame, it does not close the underlying writer.
+func (r *rawWriter) Close() error {
+	return r.writeBlock(true)
+}
+
+func (r *rawWriter) writeBlock(last bool) error {
+	if !r.started {
+		if _, err := r.w.Write(rawFrameHeader); err != nil {
+			return err
+		}
+
+		r.started = true
+	}
+
+	// Block header is little endian, with the last block flag, block type 0 (raw) and the block size.
+	h := uint32(len(r.buf)) << 3
+	if last {
+		h |= 1
+	}
+
+	if _, err := r.w.Write([]byte{byte(h), byte(h >> 8), byte(h >> 16)}); err != nil {
+		return err
+	}
+
+	if _, err := r.w.Write(r.buf); err != nil {
+		return err
+	}
+
+	r.buf = r.buf[:0]
+
+	return nil
+}
@@ -6,6 +6,7 @@ import (
 
 	"github.com/go-kit/log"
 	"github.com/klauspost/compress/zstd"
+	"github.com/meltwater/drone-cache/archive/common"
 	"github.com/meltwater/drone-cache/archive/tar"
 	"github.com/meltwater/drone-cache/internal"
 )
@@ -16,28 +17,71 @@ type Archive struct {
 
 	root             string
 	compressionLevel int
-	skipSymlinks     bool
+	opts             common.Options
 }
 
 // New creates an archive that uses the .tar.zst file format.
 func New(logger log.Logger, root string, skipSymlinks bool, compressionLevel int) *Archive {
-	return &Archive{logger, root, compressionLevel, skipSymlinks}
+	return NewWithOptions(logger, root, compressionLevel, common.Options{Symlinks: common.SkipSymlinks(skipSymlinks)})
+}
+
+// NewWithOptions creates an archive that uses the .tar.zst file format with given options.
+// Concurrency sets the number of goroutines used by the encoder and decoder, and WindowSize sets the encoder window.
+// Zero values use the defaults of the zstd library.
+// If Adaptive is set, incompressible files are stored in raw blocks instead of being recompressed.
+// The rest of the options apply to the tar stream, see tar.NewWithOptions.
+func NewWithOptions(logger log.Logger, root string, compressionLevel int, opts common.Options) *Archive {
+	return &Archive{logger: logger, root: root, compressionLevel: compressionLevel, opts: opts}
 }
 
 // Create writes content of the given source to an archive, returns written bytes.
-func (a *Archive) Create(srcs []string, w io.Writer, isRelativePath bool) (int64, error) {
+func (a *Archive) Create(srcs []string, w io.Writer, isRelativePath bool) (written int64, err error) {
+	g := common.NewGuard(a.opts.Limits)
+	defer g.Capture(&err)
+
+	w = g.ArchiveWriter(w)
+
 	level := zstd.SpeedDefault
 	if a.compressionLevel != -1 {
 		level = zstd.EncoderLevelFromZstd(a.compressionLevel)
 	}
-	zw, err := zstd.NewWriter(w, zstd.WithEncoderLevel(level))
+	opts := []zstd.EOption{zstd.WithEncoderLevel(level)}
+	if a.opts.Concurrency > 0 {
+		opts = append(opts, zstd.WithEncoderConcurrency(a.opts.Concurrency))
+	}
+
+	if a.opts.WindowSize > 0 {
+		opts = append(opts, zstd.WithWindowSize(a.opts.WindowSize))
+	}
+
+	zw, err := zstd.NewWriter(w, opts...)
 	if err != nil {
 		return 0, fmt.Errorf("zstd create archive writer, %w", err)
 	}
 
+	if a.opts.Adaptive {
+		return a.createAdaptive(srcs, w, zw, g, isRelativePath)
+	}
+
 	defer internal.CloseWithErrLogf(a.logger, zw, "zstd writer")
 
-	wBytes, err := tar.New(a.logger, a.root, a.skipSymlinks).Create(srcs, zw, isRelativePath)
+	wBytes, err := a.newTar(g).Create(srcs, zw, isRelativePath)
+	if err != nil {
+		return 0, fmt.Errorf("zstd create archive, %w", err)
+	}
+
+	return wBytes, nil
+}
+
+// createAdaptive writes incompressible files to frames of raw blocks that are stored as is,
+// and everything else to frames compressed with the configured options.
+func (a *Archive) createAdaptive(srcs []string, w io.Writer, zw *zstd.Encoder, g *common.Guard,
+	isRelativePath bool) (int64, error) {
+	// NOTICE: Concatenated zstd frames are read as a single stream.
+	mw := common.NewMemberWriter(w, zw, newRawWriter(w))
+	defer internal.CloseWithErrLogf(a.logger, mw, "zstd writer")
+
+	wBytes, err := a.newTar(g).CreateWithHook(srcs, mw, isRelativePath, mw.Hook())
 	if err != nil {
 		return 0, fmt.Errorf("zstd create archive, %w", err)
 	}
@@ -47,17 +91,50 @@ func (a *Archive) Create(srcs []string, w io.Writer, isRelativePath bool) (int64
 
 // Extract reads content from the given archive reader and restores it to the destination, returns written bytes.
 func (a *Archive) Extract(dst string, r io.Reader) (int64, error) {
-	zr, err := zstd.NewReader(r)
+	g := common.NewGuard(a.opts.Limits)
+
+	zr, err := zstd.NewReader(g.ArchiveReader(r), a.decoderOptions()...)
 	if err != nil {
 		return 0, fmt.Errorf("zstd create extract archive reader, %w", err)
 	}
 
 	defer internal.CloseWithErrLogf(a.logger, zr.IOReadCloser(), "zstd reader")
 
-	eBytes, err := tar.New(a.logger, a.root, a.skipSymlinks).Extract(dst, zr)
+	eBytes, err := a.newTar(g).Extract(dst, zr)
 	if err != nil {
 		return 0, fmt.Errorf("zstd extract archive, %w", err)
 	}
 
 	return eBytes, nil
 }
+
+// List reads content from the given archive reader and returns its entries, without extracting them.
+func (a *Archive) List(r io.Reader) ([]common.Entry, error) {
+	g := common.NewGuard(a.opts.Limits)
+
+	zr, err := zstd.NewReader(g.ArchiveReader(r), a.decoderOptions()...)
+	if err != nil {
+		return nil, fmt.Errorf("zstd create list archive reader, %w", err)
+	}
+
+	defer internal.CloseWithErrLogf(a.logger, zr.IOReadCloser(), "zstd reader")
+
+	entries, err := a.newTar(g).List(zr)
+	if err != nil {
+		return nil, fmt.Errorf("zstd list archive, %w", err)
+	}
+
+	return entries, nil
+}
+
+func (a *Archive) newTar(g *common.Guard) *tar.Archive {
+	return tar.NewWithOptions(a.logger, a.root, a.opts).WithGuard(g)
+}
+
+func (a *Archive) decoderOptions() []zstd.DOption {
+	if a.opts.Concurrency > 0 {
+		return []zstd.DOption{zstd.WithDecoderConcurrency(a.opts.Concurrency)}
+	}
+
+	return nil
+}
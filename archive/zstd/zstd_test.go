@@ -1,14 +1,17 @@
 package zstd
 
 import (
+	"bytes"
 	"compress/flate"
 	"io"
 	"io/ioutil"
+	"math/rand"
 	"os"
 	"path/filepath"
 	"testing"
 
 	"github.com/go-kit/log"
+	"github.com/klauspost/compress/zstd"
 
 	"github.com/meltwater/drone-cache/archive/tar"
 	"github.com/meltwater/drone-cache/test"
@@ -316,3 +319,66 @@ func exampleFileTreeWithSymlinks(t *testing.T, name string) []string {
 
 	return []string{file, dir, symlink}
 }
+
+func TestRawWriter(t *testing.T) {
+	t.Parallel()
+
+	data := make([]byte, 3*rawBlockSize+17)
+	rand.New(rand.NewSource(1)).Read(data) // nolint:errcheck,gosec
+
+	for _, size := range []int{0, 17, rawBlockSize, len(data)} {
+		var buf bytes.Buffer
+
+		rw := newRawWriter(&buf)
+		_, err := rw.Write(data[:size])
+		test.Ok(t, err)
+		test.Ok(t, rw.Close())
+
+		// Frames of raw blocks are read with any other frame.
+		rw.Reset(&buf)
+		_, err = rw.Write(data[:size])
+		test.Ok(t, err)
+		test.Ok(t, rw.Close())
+
+		zr, err := zstd.NewReader(&buf)
+		test.Ok(t, err)
+
+		got, err := io.ReadAll(zr)
+		zr.Close()
+		test.Ok(t, err)
+		test.Equals(t, 2*size, len(got))
+		test.Assert(t, bytes.Equal(append(data[:size:size], data[:size]...), got), "content of %d bytes differs", size)
+	}
+}
+
+// BenchmarkStoreWriter compares raw blocks to the fastest encoder without entropy coding, which still finds matches.
+func BenchmarkStoreWriter(b *testing.B) {
+	data := make([]byte, 16<<20)
+	rand.New(rand.NewSource(1)).Read(data) // nolint:errcheck,gosec
+
+	enc, err := zstd.NewWriter(io.Discard, zstd.WithEncoderLevel(zstd.SpeedFastest), zstd.WithNoEntropyCompression(true))
+	test.Ok(b, err)
+
+	for _, bc := range []struct {
+		name string
+		w    interface {
+			io.WriteCloser
+			Reset(w io.Writer)
+		}
+	}{
+		{name: "raw", w: newRawWriter(io.Discard)},
+		{name: "encoder", w: enc},
+	} {
+		bc := bc
+		b.Run(bc.name, func(b *testing.B) {
+			b.SetBytes(int64(len(data)))
+
+			for i := 0; i < b.N; i++ {
+				bc.w.Reset(io.Discard)
+				_, err := bc.w.Write(data)
+				test.Ok(b, err)
+				test.Ok(b, bc.w.Close())
+			}
+		})
+	}
+}
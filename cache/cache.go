@@ -55,11 +55,16 @@ func New(logger log.Logger, s storage.Storage, a archive.Archive, g key.Generato
 		o.apply(&options)
 	}
 
+	r := NewRestorer(log.With(logger, "component", "restorer"), s, a, g,
+		options.fallbackGenerator, options.namespace, options.failRestoreIfKeyNotPresent, options.enableCacheKeySeparator, options.strictKeyMatching, backend, accountID,
+		options.verifier, options.stateFile).(restorer)
+	r.fallbackNamespaces = options.fallbackNamespaces
+
 	return &cache{
 		NewRebuilder(log.With(logger, "component", "rebuilder"), s, a, g,
-			options.fallbackGenerator, options.namespace, options.override, options.gracefulDetect),
-		NewRestorer(log.With(logger, "component", "restorer"), s, a, g,
-			options.fallbackGenerator, options.namespace, options.failRestoreIfKeyNotPresent, options.enableCacheKeySeparator, options.strictKeyMatching, backend, accountID),
+			options.fallbackGenerator, options.namespace, options.override, options.gracefulDetect,
+			options.signer, options.provenance, options.stateFile),
+		r,
 		NewFlusher(log.With(logger, "component", "flusher"), s, time.Hour),
 	}
 }
@@ -0,0 +1,136 @@
+// Package manifest provides signed manifests that authenticate the producer of a cached archive.
+package manifest
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"strings"
+	"time"
+)
+
+const (
+	// Extension is appended to the remote path of an archive to get the path of its manifest.
+	Extension = ".manifest"
+
+	// Version of the manifest document format.
+	Version = 1
+
+	// MaxSize is the upper limit of a manifest document that is read from storage.
+	MaxSize = 64 * 1024
+
+	digestPrefix = "sha256:"
+)
+
+var (
+	// ErrUnsigned means that the manifest does not carry a signature.
+	ErrUnsigned = errors.New("manifest is not signed")
+	// ErrUnknownKey means that the manifest is signed with a key that is not trusted.
+	ErrUnknownKey = errors.New("manifest is signed with an untrusted key")
+	// ErrInvalidSignature means that the signature does not match the manifest.
+	ErrInvalidSignature = errors.New("manifest signature is invalid")
+	// ErrMismatch means that the archive does not match its manifest.
+	ErrMismatch = errors.New("archive does not match manifest")
+)
+
+// Manifest describes a cached archive and who produced it.
+type Manifest struct {
+	Version   int       `json:"version"`
+	Key       string    `json:"key"`
+	Digest    string    `json:"digest"`
+	Size      int64     `json:"size"`
+	CommitSHA string    `json:"commit_sha,omitempty"`
+	BuildLink string    `json:"build_link,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+
+	Algorithm string `json:"algorithm,omitempty"`
+	KeyID     string `json:"key_id,omitempty"`
+	Signature string `json:"signature,omitempty"`
+}
+
+// Provenance holds information about the build that produced an archive.
+type Provenance struct {
+	CommitSHA string
+	BuildLink string
+}
+
+// Path returns the remote path of the manifest for the archive at given path.
+func Path(archivePath string) string {
+	return archivePath + Extension
+}
+
+// IsManifest reports whether given remote path is a manifest.
+func IsManifest(p string) bool {
+	return strings.HasSuffix(p, Extension)
+}
+
+// New creates an unsigned manifest for the archive stored at given key.
+func New(key string, digest string, size int64, p Provenance) Manifest {
+	return Manifest{
+		Version:   Version,
+		Key:       key,
+		Digest:    digest,
+		Size:      size,
+		CommitSHA: p.CommitSHA,
+		BuildLink: p.BuildLink,
+		CreatedAt: time.Now().UTC(),
+	}
+}
+
+// NewDigester returns a hash to compute the digest of an archive with.
+func NewDigester() hash.Hash {
+	return sha256.New()
+}
+
+// Digest formats the sum of given hash as a manifest digest.
+func Digest(h hash.Hash) string {
+	return digestPrefix + hex.EncodeToString(h.Sum(nil))
+}
+
+// Match checks whether an archive with given key, digest and size is the one described by the manifest.
+func (m Manifest) Match(key, digest string, size int64) error {
+	switch {
+	case m.Key != key:
+		return fmt.Errorf("key <%s> is not <%s>, %w", m.Key, key, ErrMismatch)
+	case m.Digest != digest:
+		return fmt.Errorf("digest <%s> is not <%s>, %w", digest, m.Digest, ErrMismatch)
+	case m.Size != size:
+		return fmt.Errorf("size <%d> is not <%d>, %w", size, m.Size, ErrMismatch)
+	}
+
+	return nil
+}
+
+// payload returns the canonical representation of the manifest that is signed.
+func (m Manifest) payload() ([]byte, error) {
+	m.Signature = ""
+
+	return json.Marshal(m)
+}
+
+// Encode writes the manifest to given writer.
+func Encode(w io.Writer, m Manifest) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+
+	return enc.Encode(m)
+}
+
+// Decode reads a manifest from given reader.
+func Decode(r io.Reader) (Manifest, error) {
+	var m Manifest
+
+	if err := json.NewDecoder(io.LimitReader(r, MaxSize)).Decode(&m); err != nil {
+		return Manifest{}, fmt.Errorf("decode manifest, %w", err)
+	}
+
+	if m.Version != Version {
+		return Manifest{}, fmt.Errorf("unsupported manifest version <%d>", m.Version)
+	}
+
+	return m, nil
+}
@@ -0,0 +1,98 @@
+package manifest
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"testing"
+
+	"github.com/meltwater/drone-cache/test"
+)
+
+func TestSignAndVerify(t *testing.T) {
+	t.Parallel()
+
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	test.Ok(t, err)
+
+	edSigner, err := NewEd25519Signer(base64.StdEncoding.EncodeToString(priv.Seed()))
+	test.Ok(t, err)
+
+	hmacSigner, err := NewHMACSigner("s3cr3t")
+	test.Ok(t, err)
+
+	v, err := NewVerifier([]string{base64.StdEncoding.EncodeToString(pub)}, []string{"s3cr3t"})
+	test.Ok(t, err)
+
+	for name, s := range map[string]Signer{AlgorithmEd25519: edSigner, AlgorithmHMAC: hmacSigner} {
+		s := s
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			m := New("repo/key/vendor", "sha256:abc", 42, Provenance{CommitSHA: "deadbeef", BuildLink: "https://ci/1"})
+			test.Ok(t, s.Sign(&m))
+			test.Equals(t, name, m.Algorithm)
+
+			var buf bytes.Buffer
+			test.Ok(t, Encode(&buf, m))
+
+			decoded, err := Decode(&buf)
+			test.Ok(t, err)
+			test.Ok(t, v.Verify(decoded))
+			test.Ok(t, decoded.Match("repo/key/vendor", "sha256:abc", 42))
+
+			tampered := decoded
+			tampered.Digest = "sha256:def"
+			test.Assert(t, errors.Is(v.Verify(tampered), ErrInvalidSignature), "tampered manifest must not verify")
+
+			unsigned := decoded
+			unsigned.Signature = ""
+			test.Assert(t, errors.Is(v.Verify(unsigned), ErrUnsigned), "unsigned manifest must not verify")
+		})
+	}
+}
+
+func TestVerifyForeignKey(t *testing.T) {
+	t.Parallel()
+
+	pub, _, err := ed25519.GenerateKey(rand.Reader)
+	test.Ok(t, err)
+
+	_, foreign, err := ed25519.GenerateKey(rand.Reader)
+	test.Ok(t, err)
+
+	s, err := NewEd25519Signer(base64.StdEncoding.EncodeToString(foreign))
+	test.Ok(t, err)
+
+	v, err := NewVerifier([]string{base64.StdEncoding.EncodeToString(pub)}, []string{"s3cr3t"})
+	test.Ok(t, err)
+
+	m := New("repo/key/vendor", "sha256:abc", 42, Provenance{})
+	test.Ok(t, s.Sign(&m))
+	test.Assert(t, errors.Is(v.Verify(m), ErrUnknownKey), "manifest signed with foreign key must not verify")
+
+	h, err := NewHMACSigner("other")
+	test.Ok(t, err)
+	test.Ok(t, h.Sign(&m))
+	test.Assert(t, errors.Is(v.Verify(m), ErrUnknownKey), "manifest signed with foreign secret must not verify")
+}
+
+func TestMatch(t *testing.T) {
+	t.Parallel()
+
+	m := New("repo/key/vendor", "sha256:abc", 42, Provenance{})
+
+	test.Ok(t, m.Match("repo/key/vendor", "sha256:abc", 42))
+	test.Assert(t, errors.Is(m.Match("repo/other/vendor", "sha256:abc", 42), ErrMismatch), "key must match")
+	test.Assert(t, errors.Is(m.Match("repo/key/vendor", "sha256:def", 42), ErrMismatch), "digest must match")
+	test.Assert(t, errors.Is(m.Match("repo/key/vendor", "sha256:abc", 41), ErrMismatch), "size must match")
+}
+
+func TestNewVerifierWithoutKeys(t *testing.T) {
+	t.Parallel()
+
+	_, err := NewVerifier(nil, []string{""})
+	test.NotOk(t, err)
+}
@@ -0,0 +1,219 @@
+package manifest
+
+import (
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+const (
+	// AlgorithmEd25519 signs manifests with an ed25519 private key, verified with its public key.
+	AlgorithmEd25519 = "ed25519"
+	// AlgorithmHMAC signs and verifies manifests with a shared HMAC-SHA256 secret.
+	AlgorithmHMAC = "hmac-sha256"
+
+	keyIDLength = 8
+)
+
+// Signer signs manifests.
+type Signer interface {
+	// Sign sets the signature of given manifest.
+	Sign(m *Manifest) error
+}
+
+// Verifier verifies signatures of manifests.
+type Verifier interface {
+	// Verify checks that given manifest is signed by a trusted key.
+	Verify(m Manifest) error
+}
+
+type ed25519Signer struct {
+	id  string
+	key ed25519.PrivateKey
+}
+
+// NewEd25519Signer creates a signer with given base64 encoded ed25519 private key or seed.
+func NewEd25519Signer(encoded string) (Signer, error) {
+	b, err := decodeKey(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("decode ed25519 private key, %w", err)
+	}
+
+	var key ed25519.PrivateKey
+
+	switch len(b) {
+	case ed25519.SeedSize:
+		key = ed25519.NewKeyFromSeed(b)
+	case ed25519.PrivateKeySize:
+		key = ed25519.PrivateKey(b)
+	default:
+		return nil, fmt.Errorf("ed25519 private key must be %d or %d bytes, got %d",
+			ed25519.SeedSize, ed25519.PrivateKeySize, len(b))
+	}
+
+	pub, _ := key.Public().(ed25519.PublicKey)
+
+	return ed25519Signer{id: keyID(pub), key: key}, nil
+}
+
+// Sign sets the signature of given manifest.
+func (s ed25519Signer) Sign(m *Manifest) error {
+	m.Algorithm = AlgorithmEd25519
+	m.KeyID = s.id
+
+	p, err := m.payload()
+	if err != nil {
+		return fmt.Errorf("manifest payload, %w", err)
+	}
+
+	m.Signature = base64.StdEncoding.EncodeToString(ed25519.Sign(s.key, p))
+
+	return nil
+}
+
+type hmacSigner struct {
+	id     string
+	secret []byte
+}
+
+// NewHMACSigner creates a signer with given shared secret.
+func NewHMACSigner(secret string) (Signer, error) {
+	if secret == "" {
+		return nil, errors.New("empty hmac secret")
+	}
+
+	return hmacSigner{id: secretID([]byte(secret)), secret: []byte(secret)}, nil
+}
+
+// Sign sets the signature of given manifest.
+func (s hmacSigner) Sign(m *Manifest) error {
+	m.Algorithm = AlgorithmHMAC
+	m.KeyID = s.id
+
+	p, err := m.payload()
+	if err != nil {
+		return fmt.Errorf("manifest payload, %w", err)
+	}
+
+	m.Signature = base64.StdEncoding.EncodeToString(mac(s.secret, p))
+
+	return nil
+}
+
+// keyring verifies manifests against a set of trusted keys, indexed by their identifiers.
+type keyring struct {
+	publicKeys map[string]ed25519.PublicKey
+	secrets    map[string][]byte
+}
+
+// NewVerifier creates a verifier that trusts given base64 encoded ed25519 public keys and HMAC secrets.
+func NewVerifier(publicKeys []string, secrets []string) (Verifier, error) {
+	k := keyring{publicKeys: map[string]ed25519.PublicKey{}, secrets: map[string][]byte{}}
+
+	for _, encoded := range publicKeys {
+		b, err := decodeKey(encoded)
+		if err != nil {
+			return nil, fmt.Errorf("decode ed25519 public key, %w", err)
+		}
+
+		if len(b) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("ed25519 public key must be %d bytes, got %d", ed25519.PublicKeySize, len(b))
+		}
+
+		k.publicKeys[keyID(b)] = ed25519.PublicKey(b)
+	}
+
+	for _, s := range secrets {
+		if s == "" {
+			continue
+		}
+
+		k.secrets[secretID([]byte(s))] = []byte(s)
+	}
+
+	if len(k.publicKeys) == 0 && len(k.secrets) == 0 {
+		return nil, errors.New("no trusted keys configured to verify manifests")
+	}
+
+	return k, nil
+}
+
+// Verify checks that given manifest is signed by a trusted key.
+func (k keyring) Verify(m Manifest) error {
+	if m.Signature == "" {
+		return ErrUnsigned
+	}
+
+	sig, err := base64.StdEncoding.DecodeString(m.Signature)
+	if err != nil {
+		return fmt.Errorf("decode signature, %w", ErrInvalidSignature)
+	}
+
+	p, err := m.payload()
+	if err != nil {
+		return fmt.Errorf("manifest payload, %w", err)
+	}
+
+	switch m.Algorithm {
+	case AlgorithmEd25519:
+		pub, ok := k.publicKeys[m.KeyID]
+		if !ok {
+			return fmt.Errorf("key id <%s>, %w", m.KeyID, ErrUnknownKey)
+		}
+
+		if !ed25519.Verify(pub, p, sig) {
+			return ErrInvalidSignature
+		}
+	case AlgorithmHMAC:
+		secret, ok := k.secrets[m.KeyID]
+		if !ok {
+			return fmt.Errorf("key id <%s>, %w", m.KeyID, ErrUnknownKey)
+		}
+
+		if subtle.ConstantTimeCompare(mac(secret, p), sig) != 1 {
+			return ErrInvalidSignature
+		}
+	default:
+		return fmt.Errorf("unknown signature algorithm <%s>, %w", m.Algorithm, ErrUnknownKey)
+	}
+
+	return nil
+}
+
+// Helpers
+
+func mac(secret, p []byte) []byte {
+	h := hmac.New(sha256.New, secret)
+	h.Write(p) // nolint:errcheck
+
+	return h.Sum(nil)
+}
+
+// keyID derives a short, non-secret identifier from given key material.
+func keyID(b []byte) string {
+	sum := sha256.Sum256(b)
+
+	return hex.EncodeToString(sum[:keyIDLength])
+}
+
+// secretID derives an identifier from given secret without exposing a plain hash of it.
+func secretID(secret []byte) string {
+	return hex.EncodeToString(mac(secret, []byte("drone-cache-key-id"))[:keyIDLength])
+}
+
+func decodeKey(encoded string) ([]byte, error) {
+	encoded = strings.TrimSpace(encoded)
+
+	b, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return base64.RawStdEncoding.DecodeString(encoded)
+	}
+
+	return b, nil
+}
@@ -1,6 +1,9 @@
 package cache
 
-import "github.com/meltwater/drone-cache/key"
+import (
+	"github.com/meltwater/drone-cache/cache/manifest"
+	"github.com/meltwater/drone-cache/key"
+)
 
 type options struct {
 	namespace                  string
@@ -10,6 +13,11 @@ type options struct {
 	gracefulDetect             bool
 	enableCacheKeySeparator    bool
 	strictKeyMatching          bool
+	signer                     manifest.Signer
+	provenance                 manifest.Provenance
+	verifier                   manifest.Verifier
+	stateFile                  string
+	fallbackNamespaces         []string
 }
 
 // Option overrides behavior of Archive.
@@ -30,6 +38,13 @@ func WithNamespace(s string) Option {
 	})
 }
 
+// WithFallbackNamespaces sets namespaces that are restored from in order, if the namespace holds no archives of the key.
+func WithFallbackNamespaces(namespaces ...string) Option {
+	return optionFunc(func(o *options) {
+		o.fallbackNamespaces = namespaces
+	})
+}
+
 // WithFallbackGenerator sets fallback key generator option.
 func WithFallbackGenerator(g key.Generator) Option {
 	return optionFunc(func(o *options) {
@@ -72,3 +87,32 @@ func WithStrictKeyMatching(strictKeyMatching bool) Option {
 		o.strictKeyMatching = strictKeyMatching
 	})
 }
+
+// WithSigner sets signer to sign a manifest for every rebuilt archive.
+func WithSigner(s manifest.Signer) Option {
+	return optionFunc(func(o *options) {
+		o.signer = s
+	})
+}
+
+// WithProvenance sets build information recorded in signed manifests.
+func WithProvenance(p manifest.Provenance) Option {
+	return optionFunc(func(o *options) {
+		o.provenance = p
+	})
+}
+
+// WithVerifier sets verifier to refuse restoring archives without a manifest signed by a trusted key.
+func WithVerifier(v manifest.Verifier) Option {
+	return optionFunc(func(o *options) {
+		o.verifier = v
+	})
+}
+
+// WithStateFile sets local file to record fingerprints of restored mounts in,
+// rebuilds skip uploading mounts that did not change since they were restored.
+func WithStateFile(filename string) Option {
+	return optionFunc(func(o *options) {
+		o.stateFile = filename
+	})
+}
@@ -1,6 +1,7 @@
 package cache
 
 import (
+	"bytes"
 	"fmt"
 	"io"
 	"os"
@@ -10,6 +11,7 @@ import (
 	"time"
 
 	"github.com/meltwater/drone-cache/archive"
+	"github.com/meltwater/drone-cache/cache/manifest"
 	"github.com/meltwater/drone-cache/internal"
 	"github.com/meltwater/drone-cache/key"
 	"github.com/meltwater/drone-cache/storage"
@@ -30,11 +32,15 @@ type rebuilder struct {
 	namespace      string
 	override       bool
 	gracefulDetect bool
+
+	signer     manifest.Signer
+	provenance manifest.Provenance
+	stateFile  string
 }
 
 // NewRebuilder creates a new cache.Rebuilder.
-func NewRebuilder(logger log.Logger, s storage.Storage, a archive.Archive, g key.Generator, fg key.Generator, namespace string, override bool, gracefulDetect bool) Rebuilder { // nolint:lll
-	return rebuilder{logger, a, s, g, fg, namespace, override, gracefulDetect}
+func NewRebuilder(logger log.Logger, s storage.Storage, a archive.Archive, g key.Generator, fg key.Generator, namespace string, override bool, gracefulDetect bool, signer manifest.Signer, provenance manifest.Provenance, stateFile string) Rebuilder { // nolint:lll
+	return rebuilder{logger, a, s, g, fg, namespace, override, gracefulDetect, signer, provenance, stateFile}
 }
 
 // Rebuild rebuilds cache from the files provided with given paths.
@@ -52,8 +58,18 @@ func (r rebuilder) Rebuild(srcs []string) error {
 		wg        sync.WaitGroup
 		errs      = &internal.MultiError{}
 		namespace = filepath.ToSlash(filepath.Clean(r.namespace))
+		restored  = map[string]mountState{}
 	)
 
+	if r.stateFile != "" {
+		s, err := loadState(r.stateFile)
+		if err != nil {
+			level.Warn(r.logger).Log("msg", "ignoring state file", "err", err)
+		} else {
+			restored = s.Mounts
+		}
+	}
+
 	for _, src := range srcs {
 		if _, err := os.Lstat(src); err != nil {
 			if !r.gracefulDetect {
@@ -64,8 +80,18 @@ func (r rebuilder) Rebuild(srcs []string) error {
 
 		dst := filepath.Join(namespace, key, src)
 
-		// If no override is set and object already exists in storage, skip it.
-		if !r.override {
+		// If mount was restored from the same object, it is only uploaded again when its content changed.
+		if m, ok := restored[filepath.ToSlash(dst)]; ok {
+			unchanged, err := r.unchanged(src, dst, m)
+			if err != nil {
+				level.Warn(r.logger).Log("msg", "could not compare with restored state, uploading", "local", src, "err", err)
+			} else if unchanged {
+				level.Info(r.logger).Log("msg", "skipping upload, cache content unchanged since restore", "local", src)
+				continue
+			}
+			// NOTICE: Changed content is uploaded even if override is not set, otherwise it would never be updated.
+		} else if !r.override {
+			// If no override is set and object already exists in storage, skip it.
 			exists, err := r.s.Exists(dst)
 			if err != nil {
 				return fmt.Errorf("destination <%s> existence check, %w", dst, err)
@@ -137,7 +163,8 @@ func (r rebuilder) rebuild(src, dst string) (err error) {
 	level.Debug(r.logger).Log("msg", "uploading archived directory", "local", src, "remote", dst)
 
 	sw := &statWriter{}
-	tr := io.TeeReader(pr, sw)
+	h := manifest.NewDigester()
+	tr := io.TeeReader(pr, io.MultiWriter(sw, h))
 
 	if err := r.s.Put(dst, tr); err != nil {
 		err = fmt.Errorf("upload file, pipe reader failed, %w", err)
@@ -148,6 +175,12 @@ func (r rebuilder) rebuild(src, dst string) (err error) {
 		return err
 	}
 
+	if r.signer != nil {
+		if err := r.sign(dst, manifest.Digest(h), sw.written); err != nil {
+			return fmt.Errorf("sign manifest, %w", err)
+		}
+	}
+
 	level.Info(r.logger).Log("msg", "uploaded cache", "src", src, "size before compression", humanize.Bytes(uint64(sw.written)), "size after compression", humanize.Bytes(uint64(written)))
 
 	level.Debug(r.logger).Log(
@@ -162,6 +195,54 @@ func (r rebuilder) rebuild(src, dst string) (err error) {
 	return nil
 }
 
+// unchanged reports whether the local mount still matches the state recorded when it was restored,
+// and the object it was restored from still exists in storage.
+func (r rebuilder) unchanged(src, dst string, m mountState) (bool, error) {
+	fp, err := fingerprint(src)
+	if err != nil {
+		return false, err
+	}
+
+	level.Debug(r.logger).Log("msg", "comparing fingerprints", "local", src, "restored", m.Fingerprint, "current", fp)
+
+	if fp != m.Fingerprint {
+		level.Info(r.logger).Log("msg", "cache content changed since restore, uploading", "local", src)
+		return false, nil
+	}
+
+	exists, err := r.s.Exists(dst)
+	if err != nil {
+		return false, fmt.Errorf("destination <%s> existence check, %w", dst, err)
+	}
+
+	if !exists {
+		level.Info(r.logger).Log("msg", "cache content unchanged, but restored object no longer exists, uploading", "remote", dst)
+	}
+
+	return exists, nil
+}
+
+// sign uploads a signed manifest next to the archive uploaded to dst.
+func (r rebuilder) sign(dst, digest string, size int64) error {
+	m := manifest.New(filepath.ToSlash(dst), digest, size, r.provenance)
+	if err := r.signer.Sign(&m); err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	if err := manifest.Encode(&buf, m); err != nil {
+		return fmt.Errorf("encode manifest, %w", err)
+	}
+
+	if err := r.s.Put(manifest.Path(dst), &buf); err != nil {
+		return fmt.Errorf("upload manifest, %w", err)
+	}
+
+	level.Debug(r.logger).Log("msg", "manifest signed", "remote", manifest.Path(dst), "key_id", m.KeyID, "digest", digest)
+
+	return nil
+}
+
 // Helpers
 
 func (r rebuilder) generateKey(parts ...string) (string, error) {
@@ -1,6 +1,7 @@
 package cache
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -16,6 +17,7 @@ import (
 	"github.com/go-kit/kit/log/level"
 
 	"github.com/meltwater/drone-cache/archive"
+	"github.com/meltwater/drone-cache/cache/manifest"
 	"github.com/meltwater/drone-cache/internal"
 	"github.com/meltwater/drone-cache/key"
 	"github.com/meltwater/drone-cache/storage"
@@ -36,12 +38,16 @@ type restorer struct {
 	strictKeyMatching       bool
 	backend                 string
 	accountID               string
+	verifier                manifest.Verifier
+	stateFile               string
+	// fallbackNamespaces are restored from in order, if the namespace holds no archives of the key.
+	fallbackNamespaces []string
 }
 
 var cacheFileMutex sync.Mutex // To ensure thread-safe writes to the file
 
 // NewRestorer creates a new cache.Restorer.
-func NewRestorer(logger log.Logger, s storage.Storage, a archive.Archive, g key.Generator, fg key.Generator, namespace string, failIfKeyNotPresent bool, enableCacheKeySeparator bool, strictKeyMatching bool, backend, accountID string) Restorer { // nolint:lll
+func NewRestorer(logger log.Logger, s storage.Storage, a archive.Archive, g key.Generator, fg key.Generator, namespace string, failIfKeyNotPresent bool, enableCacheKeySeparator bool, strictKeyMatching bool, backend, accountID string, verifier manifest.Verifier, stateFile string) Restorer { // nolint:lll
 	return restorer{
 		logger:                  logger,
 		a:                       a,
@@ -54,6 +60,8 @@ func NewRestorer(logger log.Logger, s storage.Storage, a archive.Archive, g key.
 		strictKeyMatching:       strictKeyMatching,
 		backend:                 backend,
 		accountID:               accountID,
+		verifier:                verifier,
+		stateFile:               stateFile,
 	}
 }
 
@@ -68,10 +76,16 @@ func (r restorer) Restore(dsts []string, cacheFileName string) error {
 		return fmt.Errorf("generate key, %w", err)
 	}
 
+	namespace, err := r.resolveNamespace(key, dsts)
+	if err != nil {
+		return fmt.Errorf("resolve namespace, %w", err)
+	}
+
 	var (
-		wg        sync.WaitGroup
-		errs      = &internal.MultiError{}
-		namespace = filepath.ToSlash(filepath.Clean(r.namespace))
+		wg       sync.WaitGroup
+		errs     = &internal.MultiError{}
+		mu       sync.Mutex
+		restored = map[string]mountState{}
 	)
 
 	// A map to store the original paths for each destination
@@ -96,6 +110,10 @@ func (r restorer) Restore(dsts []string, cacheFileName string) error {
 				entryPath := e.Path
 				var dst string
 
+				if manifest.IsManifest(entryPath) {
+					continue
+				}
+
 				level.Info(r.logger).Log("msg", "processing entry", "entryPath", entryPath, "prefix", prefix, "key", key)
 
 				// Check if we're in strict matching mode and skip entries that don't exactly match the key pattern
@@ -240,12 +258,33 @@ func (r restorer) Restore(dsts []string, cacheFileName string) error {
 
 			if err := r.restore(src, dst, cacheFileName); err != nil {
 				errs.Add(fmt.Errorf("download from <%s> to <%s>, %w", src, dst, err))
+				return
+			}
+
+			if r.stateFile == "" {
+				return
+			}
+
+			fp, err := fingerprint(dst)
+			if err != nil {
+				level.Warn(r.logger).Log("msg", "fingerprint restored directory", "local", dst, "err", err)
+				return
 			}
+
+			mu.Lock()
+			restored[filepath.ToSlash(src)] = mountState{Local: dst, Fingerprint: fp}
+			mu.Unlock()
 		}(src, dst)
 	}
 
 	wg.Wait()
 
+	if len(restored) > 0 {
+		if err := updateState(r.stateFile, restored); err != nil {
+			level.Warn(r.logger).Log("msg", "update state file", "err", err)
+		}
+	}
+
 	if errs.Err() != nil {
 		return fmt.Errorf("restore failed, %w", errs)
 	}
@@ -256,7 +295,41 @@ func (r restorer) Restore(dsts []string, cacheFileName string) error {
 }
 
 // restore fetches the archived file from the cache and restores to the host machine's file system.
-func (r restorer) restore(src, dst, cacheFileName string) (err error) {
+func (r restorer) restore(src, dst, cacheFileName string) error {
+	var (
+		written int64
+		err     error
+	)
+
+	if r.verifier != nil {
+		written, err = r.extractVerified(src, dst)
+	} else {
+		written, err = r.extract(src, dst)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	err = writeCacheMetadata(CacheMetadata{CacheSizeBytes: uint64(written), Dstpath: dst}, cacheFileName)
+	if err != nil {
+		level.Error(r.logger).Log("msg", "writeCacheMetadata", "err", err)
+	}
+
+	level.Info(r.logger).Log("msg", "downloaded to local", "directory", dst, "cache size", humanize.Bytes(uint64(written)))
+
+	level.Debug(r.logger).Log(
+		"msg", "archive extracted",
+		"local", dst,
+		"remote", src,
+		"raw size", written,
+	)
+
+	return nil
+}
+
+// extract streams the archived file from the cache and extracts it to the host machine's file system.
+func (r restorer) extract(src, dst string) (written int64, err error) {
 	pr, pw := io.Pipe()
 	defer internal.CloseWithErrCapturef(&err, pr, "rebuild, pr close <%s>", dst)
 
@@ -274,35 +347,121 @@ func (r restorer) restore(src, dst, cacheFileName string) (err error) {
 
 	level.Debug(r.logger).Log("msg", "extracting archived directory", "remote", src, "local", dst)
 
-	written, err := r.a.Extract(dst, pr)
+	written, err = r.a.Extract(dst, pr)
 	if err != nil {
 		err = fmt.Errorf("extract files from downloaded archive, pipe reader failed, %w", err)
 		if err := pr.CloseWithError(err); err != nil {
 			level.Error(r.logger).Log("msg", "pr close", "err", err)
 		}
 
-		return err
+		return 0, err
 	}
 
-	err = writeCacheMetadata(CacheMetadata{CacheSizeBytes: uint64(written), Dstpath: dst}, cacheFileName)
+	return written, nil
+}
+
+// extractVerified downloads the archived file to a temporary file, checks it against its signed manifest,
+// and only then extracts it to the host machine's file system.
+func (r restorer) extractVerified(src, dst string) (written int64, err error) {
+	var buf bytes.Buffer
+	if err := r.s.Get(manifest.Path(src), &buf); err != nil {
+		return 0, fmt.Errorf("get manifest <%s>, refusing unsigned archive, %w", manifest.Path(src), err)
+	}
+
+	m, err := manifest.Decode(&buf)
 	if err != nil {
-		level.Error(r.logger).Log("msg", "writeCacheMetadata", "err", err)
+		return 0, fmt.Errorf("manifest <%s>, %w", manifest.Path(src), err)
 	}
 
-	level.Info(r.logger).Log("msg", "downloaded to local", "directory", dst, "cache size", humanize.Bytes(uint64(written)))
+	if err := r.verifier.Verify(m); err != nil {
+		return 0, fmt.Errorf("verify manifest <%s>, %w", manifest.Path(src), err)
+	}
 
-	level.Debug(r.logger).Log(
-		"msg", "archive extracted",
-		"local", dst,
-		"remote", src,
-		"raw size", written,
+	f, err := os.CreateTemp("", "drone-cache-*")
+	if err != nil {
+		return 0, fmt.Errorf("create temporary file, %w", err)
+	}
+
+	defer func() {
+		internal.CloseWithErrCapturef(&err, f, "extract verified, close temporary file <%s>", f.Name())
+
+		if rErr := os.Remove(f.Name()); rErr != nil {
+			level.Error(r.logger).Log("msg", "remove temporary file", "err", rErr)
+		}
+	}()
+
+	level.Debug(r.logger).Log("msg", "downloading archived directory", "remote", src, "local", f.Name())
+
+	var (
+		sw = &statWriter{}
+		h  = manifest.NewDigester()
 	)
 
-	return nil
+	if err := r.s.Get(src, io.MultiWriter(f, sw, h)); err != nil {
+		return 0, fmt.Errorf("get file from storage backend, %w", err)
+	}
+
+	if err := m.Match(filepath.ToSlash(src), manifest.Digest(h), sw.written); err != nil {
+		return 0, fmt.Errorf("verify archive <%s>, %w", src, err)
+	}
+
+	level.Debug(r.logger).Log("msg", "archive verified", "remote", src, "key_id", m.KeyID, "commit", m.CommitSHA)
+
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return 0, fmt.Errorf("seek temporary file, %w", err)
+	}
+
+	level.Debug(r.logger).Log("msg", "extracting archived directory", "remote", src, "local", dst)
+
+	written, err = r.a.Extract(dst, f)
+	if err != nil {
+		return 0, fmt.Errorf("extract files from downloaded archive, %w", err)
+	}
+
+	return written, nil
 }
 
 // Helpers
 
+// resolveNamespace returns the first of the namespace and the fallback namespaces that holds archives of given key.
+// The namespace is returned if none does, so that missing keys are reported as before.
+func (r restorer) resolveNamespace(key string, dsts []string) (string, error) {
+	namespace := filepath.ToSlash(filepath.Clean(r.namespace))
+
+	for _, ns := range r.fallbackNamespaces {
+		ok, err := r.holdsKey(namespace, key, dsts)
+		if err != nil || ok {
+			return namespace, err
+		}
+
+		level.Info(r.logger).Log("msg", "no cache in namespace, falling back", "namespace", namespace, "fallback", ns)
+		namespace = filepath.ToSlash(filepath.Clean(ns))
+	}
+
+	return namespace, nil
+}
+
+// holdsKey checks whether given namespace holds an archive of given key, for any of given directories.
+func (r restorer) holdsKey(namespace, key string, dsts []string) (bool, error) {
+	if len(dsts) == 0 {
+		entries, err := r.s.List(filepath.Join(namespace, key))
+		if err == common.ErrNotImplemented {
+			return true, nil
+		}
+
+		return len(entries) > 0, err
+	}
+
+	for _, dst := range dsts {
+		ok, err := r.s.Exists(filepath.Join(namespace, key, dst))
+		if err != nil || ok {
+			return ok, err
+		}
+	}
+
+	return false, nil
+}
+
 func (r restorer) generateKey(parts ...string) (string, error) {
 	key, err := r.g.Generate(parts...)
 	if err == nil {
@@ -1,13 +1,16 @@
 package cache
 
 import (
+	"fmt"
 	"io"
 	"sort"
+	"strings"
 	"sync"
 	"testing"
 	"time"
 
 	"github.com/go-kit/kit/log"
+	"github.com/meltwater/drone-cache/cache/manifest"
 	"github.com/meltwater/drone-cache/key/generator"
 	"github.com/meltwater/drone-cache/storage/common"
 )
@@ -669,3 +672,176 @@ func TestCacheKeySeparatorOptions(t *testing.T) {
 		})
 	}
 }
+
+// Tests that restores with a verifier only extract archives with a manifest signed by a trusted key
+func TestRestoreVerifiesManifest(t *testing.T) {
+	const secret = "s3cr3t"
+
+	var (
+		mu      sync.Mutex
+		objects = map[string][]byte{}
+	)
+
+	storage := &MockStorage{
+		PutFunc: func(p string, r io.Reader) error {
+			b, err := io.ReadAll(r)
+			mu.Lock()
+			objects[p] = b
+			mu.Unlock()
+			return err
+		},
+		GetFunc: func(p string, w io.Writer) error {
+			mu.Lock()
+			b, ok := objects[p]
+			mu.Unlock()
+			if !ok {
+				return fmt.Errorf("object <%s> not found", p)
+			}
+			_, err := w.Write(b)
+			return err
+		},
+	}
+
+	arc := &MockArchive{
+		CreateFunc: func(srcs []string, w io.Writer, _ bool) (int64, error) {
+			n, err := w.Write([]byte("archived " + strings.Join(srcs, ",")))
+			return int64(n), err
+		},
+		ExtractFunc: func(dst string, r io.Reader) (int64, error) {
+			n, err := io.Copy(io.Discard, r)
+			return n, err
+		},
+	}
+
+	signer, err := manifest.NewHMACSigner(secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rb := NewRebuilder(log.NewNopLogger(), storage, arc, generator.NewStatic("key"), nil, "repo", true, true,
+		signer, manifest.Provenance{CommitSHA: "deadbeef"}, "")
+	if err := rb.Rebuild([]string{"vendor"}); err != nil {
+		t.Fatalf("Error calling Rebuild: %v", err)
+	}
+
+	if _, ok := objects[manifest.Path("repo/key/vendor")]; !ok {
+		t.Fatalf("Expected manifest to be uploaded next to the archive, got %v", objects)
+	}
+
+	newRestorer := func(secret string) restorer {
+		v, err := manifest.NewVerifier(nil, []string{secret})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return restorer{
+			logger:    log.NewNopLogger(),
+			a:         arc,
+			s:         storage,
+			g:         generator.NewStatic("key"),
+			namespace: "repo",
+			verifier:  v,
+		}
+	}
+
+	if err := newRestorer(secret).Restore([]string{"vendor"}, ""); err != nil {
+		t.Fatalf("Expected signed archive to be restored, got: %v", err)
+	}
+
+	if err := newRestorer("foreign").Restore([]string{"vendor"}, ""); err == nil {
+		t.Error("Expected archive signed with an untrusted key to be refused")
+	}
+
+	objects["repo/key/vendor"] = []byte("poisoned")
+	if err := newRestorer(secret).Restore([]string{"vendor"}, ""); err == nil {
+		t.Error("Expected archive that does not match its manifest to be refused")
+	}
+
+	delete(objects, manifest.Path("repo/key/vendor"))
+	if err := newRestorer(secret).Restore([]string{"vendor"}, ""); err == nil {
+		t.Error("Expected unsigned archive to be refused")
+	}
+
+	if len(arc.ExtractCalls) != 1 {
+		t.Errorf("Expected only the verified archive to be extracted, got %d extractions", len(arc.ExtractCalls))
+	}
+}
+
+func TestRestoreFallsBackFromScopedNamespace(t *testing.T) {
+	dir := t.TempDir()
+
+	var (
+		mu       sync.Mutex
+		objects  = map[string][]byte{}
+		restored string
+	)
+
+	storage := &MockStorage{
+		PutFunc: func(p string, r io.Reader) error {
+			b, err := io.ReadAll(r)
+			mu.Lock()
+			objects[p] = b
+			mu.Unlock()
+			return err
+		},
+		GetFunc: func(p string, w io.Writer) error {
+			mu.Lock()
+			b, ok := objects[p]
+			mu.Unlock()
+			if !ok {
+				return fmt.Errorf("object <%s> not found", p)
+			}
+			_, err := w.Write(b)
+			return err
+		},
+		ExistsFunc: func(p string) (bool, error) {
+			mu.Lock()
+			defer mu.Unlock()
+			_, ok := objects[p]
+			return ok, nil
+		},
+	}
+
+	newCache := func(content string, opts ...Option) Cache {
+		arc := &MockArchive{
+			CreateFunc: func(srcs []string, w io.Writer, _ bool) (int64, error) {
+				n, err := w.Write([]byte(content))
+				return int64(n), err
+			},
+			ExtractFunc: func(dst string, r io.Reader) (int64, error) {
+				b, err := io.ReadAll(r)
+				restored = string(b)
+				return int64(len(b)), err
+			},
+		}
+
+		return New(log.NewNopLogger(), storage, arc, generator.NewStatic("key"), "", "", append(opts, WithOverride(true))...)
+	}
+
+	if err := newCache("shared").Rebuild([]string{dir}); err != nil {
+		t.Fatalf("Error rebuilding shared cache: %v", err)
+	}
+
+	scoped := []Option{WithNamespace("pr-42"), WithFallbackNamespaces("")}
+
+	// Scoped runs read the shared keys until they wrote their own cache.
+	if err := newCache("").Restore([]string{dir}, ""); err != nil || restored != "shared" {
+		t.Fatalf("Expected shared cache to be restored, got %q, %v", restored, err)
+	}
+
+	if err := newCache("", scoped...).Restore([]string{dir}, ""); err != nil || restored != "shared" {
+		t.Fatalf("Expected scoped restore to fall back to shared cache, got %q, %v", restored, err)
+	}
+
+	if err := newCache("scoped", scoped...).Rebuild([]string{dir}); err != nil {
+		t.Fatalf("Error rebuilding scoped cache: %v", err)
+	}
+
+	if err := newCache("", scoped...).Restore([]string{dir}, ""); err != nil || restored != "scoped" {
+		t.Fatalf("Expected scoped cache to be read back, got %q, %v", restored, err)
+	}
+
+	if err := newCache("").Restore([]string{dir}, ""); err != nil || restored != "shared" {
+		t.Fatalf("Expected shared cache to stay unchanged, got %q, %v", restored, err)
+	}
+}
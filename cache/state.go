@@ -0,0 +1,145 @@
+package cache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+)
+
+const stateVersion = 1
+
+var stateFileMutex sync.Mutex // To ensure thread-safe writes to the file
+
+// state records content fingerprints of restored mounts, to detect unchanged mounts on rebuild.
+type state struct {
+	Version int                   `json:"version"`
+	Mounts  map[string]mountState `json:"mounts"`
+}
+
+// mountState is the fingerprint of a local mount restored from a remote path.
+type mountState struct {
+	Local       string `json:"local"`
+	Fingerprint string `json:"fingerprint"`
+}
+
+// loadState reads the state file, a missing file is an empty state.
+func loadState(filename string) (state, error) {
+	s := state{Version: stateVersion, Mounts: map[string]mountState{}}
+
+	b, err := os.ReadFile(filename)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+
+	if err != nil {
+		return s, fmt.Errorf("read state file <%s>, %w", filename, err)
+	}
+
+	if err := json.Unmarshal(b, &s); err != nil {
+		return s, fmt.Errorf("unmarshal state file <%s>, %w", filename, err)
+	}
+
+	if s.Version != stateVersion {
+		return state{Version: stateVersion, Mounts: map[string]mountState{}}, nil
+	}
+
+	if s.Mounts == nil {
+		s.Mounts = map[string]mountState{}
+	}
+
+	return s, nil
+}
+
+// updateState merges given mounts into the state file.
+func updateState(filename string, mounts map[string]mountState) error {
+	stateFileMutex.Lock()
+	defer stateFileMutex.Unlock()
+
+	s, err := loadState(filename)
+	if err != nil {
+		return err
+	}
+
+	for remote, m := range mounts {
+		s.Mounts[remote] = m
+	}
+
+	b, err := json.MarshalIndent(s, "", "\t")
+	if err != nil {
+		return fmt.Errorf("marshal state, %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(filename), 0755); err != nil { // nolint:gomnd
+		return fmt.Errorf("create state file directory, %w", err)
+	}
+
+	if err := os.WriteFile(filename, b, 0644); err != nil { // nolint:gomnd
+		return fmt.Errorf("write state file <%s>, %w", filename, err)
+	}
+
+	return nil
+}
+
+// fingerprint computes a digest of the tree under given root from relative paths, modes, sizes,
+// modification times and link targets of its entries, without reading file contents.
+func fingerprint(root string) (string, error) {
+	type entry struct {
+		path string
+		line string
+	}
+
+	var entries []entry
+
+	err := filepath.Walk(root, func(p string, fi fs.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(root, p)
+		if err != nil {
+			return err
+		}
+
+		var link string
+
+		if fi.Mode()&os.ModeSymlink != 0 {
+			if link, err = os.Readlink(p); err != nil {
+				return err
+			}
+		}
+
+		rel = filepath.ToSlash(rel)
+		entries = append(entries, entry{rel, fmt.Sprintf("%s\x00%s\x00%d\x00%d\x00%s\n",
+			rel, fi.Mode(), sizeOf(fi), fi.ModTime().UnixNano(), link)})
+
+		return nil
+	})
+	if err != nil {
+		return "", fmt.Errorf("walk <%s>, %w", root, err)
+	}
+
+	sort.Slice(entries, func(i, j int) bool { return entries[i].path < entries[j].path })
+
+	h := sha256.New()
+	for _, e := range entries {
+		h.Write([]byte(e.line)) // nolint:errcheck
+	}
+
+	return "sha256:" + hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// sizeOf returns the size of regular files, sizes of directories depend on the file system.
+func sizeOf(fi fs.FileInfo) int64 {
+	if fi.Mode().IsRegular() {
+		return fi.Size()
+	}
+
+	return 0
+}
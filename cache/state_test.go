@@ -0,0 +1,80 @@
+package cache
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/log"
+
+	"github.com/meltwater/drone-cache/cache/manifest"
+	"github.com/meltwater/drone-cache/key/generator"
+	"github.com/meltwater/drone-cache/test"
+)
+
+func TestFingerprint(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	file := filepath.Join(dir, "file")
+	test.Ok(t, os.WriteFile(file, []byte("content"), 0644))
+
+	fp1, err := fingerprint(dir)
+	test.Ok(t, err)
+
+	fp2, err := fingerprint(dir)
+	test.Ok(t, err)
+	test.Equals(t, fp1, fp2)
+
+	later := time.Now().Add(time.Hour)
+	test.Ok(t, os.Chtimes(file, later, later))
+
+	fp3, err := fingerprint(dir)
+	test.Ok(t, err)
+	test.Assert(t, fp1 != fp3, "fingerprint must change with modification time")
+
+	_, err = fingerprint(filepath.Join(dir, "missing"))
+	test.NotOk(t, err)
+}
+
+func TestRebuildSkipsUnchangedSinceRestore(t *testing.T) {
+	t.Parallel()
+
+	var (
+		dir       = t.TempDir()
+		src       = filepath.Join(dir, "vendor")
+		stateFile = filepath.Join(dir, "state", "state.json")
+		dst       = filepath.ToSlash(filepath.Join("repo", "key", src))
+	)
+
+	test.Ok(t, os.MkdirAll(src, 0755))
+	test.Ok(t, os.WriteFile(filepath.Join(src, "file"), []byte("content"), 0644))
+
+	fp, err := fingerprint(src)
+	test.Ok(t, err)
+	test.Ok(t, updateState(stateFile, map[string]mountState{dst: {Local: src, Fingerprint: fp}}))
+
+	var uploads int
+
+	s := &MockStorage{
+		ExistsFunc: func(p string) (bool, error) { return true, nil },
+		PutFunc: func(p string, r io.Reader) error {
+			uploads++
+			_, err := io.Copy(io.Discard, r)
+			return err
+		},
+	}
+
+	rb := NewRebuilder(log.NewNopLogger(), s, &MockArchive{}, generator.NewStatic("key"), nil, "repo", false, false,
+		nil, manifest.Provenance{}, stateFile)
+
+	test.Ok(t, rb.Rebuild([]string{src}))
+	test.Equals(t, 0, uploads)
+
+	// NOTICE: Changed content is uploaded even if override is not set.
+	test.Ok(t, os.WriteFile(filepath.Join(src, "new"), []byte("content"), 0644))
+	test.Ok(t, rb.Rebuild([]string{src}))
+	test.Equals(t, 1, uploads)
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/go-kit/kit/log"
+	"github.com/urfave/cli/v2"
+
+	"github.com/meltwater/drone-cache/archive"
+	"github.com/meltwater/drone-cache/internal/admin"
+	"github.com/meltwater/drone-cache/storage"
+	"github.com/meltwater/drone-cache/storage/backend"
+)
+
+// commands returns administrative subcommands, they use the same backend flags as the plugin itself.
+func commands() []*cli.Command {
+	output := &cli.StringFlag{
+		Name:  "output",
+		Usage: "output format to use. ('table', 'json')",
+		Value: admin.DefaultOutput,
+	}
+
+	return []*cli.Command{
+		{
+			Name:      "ls",
+			Usage:     "list cached objects under given prefix",
+			ArgsUsage: "<prefix>",
+			Flags:     []cli.Flag{output},
+			Action: func(c *cli.Context) error {
+				adm, err := newAdmin(c)
+				if err != nil {
+					return err
+				}
+
+				entries, err := adm.List(c.Args().First())
+				if err != nil {
+					return err
+				}
+
+				return admin.Write(os.Stdout, c.String("output"), entries)
+			},
+		},
+		{
+			Name:      "inspect",
+			Usage:     "list entries and sizes of a cached archive without extracting it",
+			ArgsUsage: "<key>",
+			Flags:     []cli.Flag{output},
+			Action: func(c *cli.Context) error {
+				if c.NArg() != 1 {
+					return errors.New("inspect requires exactly one <key> argument")
+				}
+
+				adm, err := newAdmin(c)
+				if err != nil {
+					return err
+				}
+
+				entries, err := adm.Inspect(c.Args().First())
+				if err != nil {
+					return err
+				}
+
+				return admin.Write(os.Stdout, c.String("output"), entries)
+			},
+		},
+		{
+			Name:      "rm",
+			Usage:     "remove cached object with given key, or all objects under given prefix",
+			ArgsUsage: "<key|prefix>",
+			Flags: []cli.Flag{
+				output,
+				&cli.BoolFlag{
+					Name:  "dry-run",
+					Usage: "only list objects that would be removed",
+				},
+			},
+			Action: func(c *cli.Context) error {
+				if c.NArg() != 1 {
+					return errors.New("rm requires exactly one <key|prefix> argument")
+				}
+
+				adm, err := newAdmin(c)
+				if err != nil {
+					return err
+				}
+
+				removed, err := adm.Remove(c.Args().First(), c.Bool("dry-run"))
+				if len(removed) > 0 {
+					if wErr := admin.Write(os.Stdout, c.String("output"), removed); wErr != nil && err == nil {
+						err = wErr
+					}
+				}
+
+				return err
+			},
+		},
+		{
+			Name:      "du",
+			Usage:     "summarize storage used under given namespace, per cache key",
+			ArgsUsage: "<namespace>",
+			Flags:     []cli.Flag{output},
+			Action: func(c *cli.Context) error {
+				adm, err := newAdmin(c)
+				if err != nil {
+					return err
+				}
+
+				usage, err := adm.DiskUsage(c.Args().First())
+				if err != nil {
+					return err
+				}
+
+				return admin.Write(os.Stdout, c.String("output"), usage)
+			},
+		},
+	}
+}
+
+func newAdmin(c *cli.Context) (*admin.Admin, error) {
+	logger := newLogger(c)
+
+	b, err := backend.FromConfig(logger, c.String("backend"), backendConfig(c))
+	if err != nil {
+		return nil, fmt.Errorf("initialize backend <%s>, %w", c.String("backend"), err)
+	}
+
+	a, err := archive.FromFormat(logger, "", c.String("archive-format"),
+		archive.WithSkipSymlinks(c.Bool("skip-symlinks")),
+		archive.WithCompressionLevel(c.Int("compression-level")),
+		archive.WithConcurrency(c.Int("compression-concurrency")),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("initialize archive, %w", err)
+	}
+
+	return admin.New(log.With(logger, "component", "admin"),
+		storage.New(logger, b, c.Duration("backend.operation-timeout")), a), nil
+}
@@ -9,12 +9,16 @@ require (
 	github.com/go-kit/log v0.2.1
 	github.com/google/go-cmp v0.5.9
 	github.com/klauspost/compress v1.16.3
+	github.com/klauspost/pgzip v1.2.6
+	github.com/pierrec/lz4/v4 v4.1.21
 	github.com/pkg/sftp v1.13.5
 	github.com/sirupsen/logrus v1.9.3
+	github.com/ulikunitz/xz v0.5.12
 	github.com/urfave/cli/v2 v2.25.0
 	golang.org/x/crypto v0.14.0
 	golang.org/x/oauth2 v0.6.0
 	google.golang.org/api v0.114.0
+	gopkg.in/yaml.v2 v2.4.0
 )
 
 require (
@@ -45,7 +49,6 @@ require (
 	google.golang.org/genproto v0.0.0-20230320184635-7606e756e683 // indirect
 	google.golang.org/grpc v1.54.0 // indirect
 	google.golang.org/protobuf v1.30.0 // indirect
-	gopkg.in/yaml.v2 v2.4.0 // indirect
 )
 
 go 1.22.4
@@ -0,0 +1,216 @@
+// Package admin provides maintenance operations to inspect and manage cached objects in storage backends.
+package admin
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+
+	"github.com/meltwater/drone-cache/archive"
+	arccommon "github.com/meltwater/drone-cache/archive/common"
+	"github.com/meltwater/drone-cache/cache/manifest"
+	"github.com/meltwater/drone-cache/internal"
+	"github.com/meltwater/drone-cache/storage"
+	"github.com/meltwater/drone-cache/storage/common"
+)
+
+var (
+	// ErrEmptyTarget means that no key or prefix given to operate on.
+	ErrEmptyTarget = errors.New("empty key or prefix")
+	// ErrNotListable means that configured archive format can not list its entries.
+	ErrNotListable = errors.New("archive format does not support listing entries")
+	// ErrNotFound means that no object found with given key or prefix.
+	ErrNotFound = errors.New("no object found")
+)
+
+// Admin runs maintenance operations against a storage.
+type Admin struct {
+	logger log.Logger
+
+	s storage.Storage
+	a archive.Archive
+}
+
+// Usage summarizes storage used under a namespace.
+type Usage struct {
+	Namespace string     `json:"namespace"`
+	Objects   int        `json:"objects"`
+	Size      int64      `json:"size_bytes"`
+	Keys      []KeyUsage `json:"keys"`
+}
+
+// KeyUsage summarizes storage used by a single cache key.
+type KeyUsage struct {
+	Key          string    `json:"key"`
+	Objects      int       `json:"objects"`
+	Size         int64     `json:"size_bytes"`
+	LastModified time.Time `json:"last_modified"`
+}
+
+// New creates a new Admin.
+func New(logger log.Logger, s storage.Storage, a archive.Archive) *Admin {
+	return &Admin{logger: logger, s: s, a: a}
+}
+
+// List lists cached objects under the given prefix.
+func (a *Admin) List(prefix string) ([]common.FileEntry, error) {
+	entries, err := a.s.List(prefix)
+	if err != nil {
+		return nil, fmt.Errorf("list <%s>, %w", prefix, err)
+	}
+
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Path < entries[j].Path })
+
+	return entries, nil
+}
+
+// Inspect lists entries of the cached archive with the given key, without extracting them.
+func (a *Admin) Inspect(key string) (entries []arccommon.Entry, err error) {
+	if key == "" {
+		return nil, ErrEmptyTarget
+	}
+
+	l, ok := a.a.(archive.Lister)
+	if !ok {
+		return nil, ErrNotListable
+	}
+
+	pr, pw := io.Pipe()
+	defer internal.CloseWithErrCapturef(&err, pr, "inspect, pr close <%s>", key)
+
+	go func() {
+		defer internal.CloseWithErrLogf(a.logger, pw, "pw close defer")
+
+		if err := a.s.Get(key, pw); err != nil {
+			if err := pw.CloseWithError(fmt.Errorf("get file from storage backend, pipe writer failed, %w", err)); err != nil {
+				level.Error(a.logger).Log("msg", "pw close", "err", err)
+			}
+		}
+	}()
+
+	entries, err = l.List(pr)
+	if err != nil {
+		return nil, fmt.Errorf("list archive entries <%s>, %w", key, err)
+	}
+
+	// NOTICE: Drain the rest of the stream, archives might have trailing padding.
+	if _, err := io.Copy(io.Discard, pr); err != nil {
+		return nil, fmt.Errorf("read archive <%s>, %w", key, err)
+	}
+
+	return entries, nil
+}
+
+// Remove removes the object with the given key, or every object under it when it is a prefix.
+// If dryRun is true, it only returns the objects that would have been removed.
+func (a *Admin) Remove(target string, dryRun bool) ([]common.FileEntry, error) {
+	target = strings.TrimSpace(target)
+	if target == "" || target == "/" {
+		return nil, ErrEmptyTarget
+	}
+
+	entries, err := a.s.List(target)
+	if errors.Is(err, common.ErrNotImplemented) {
+		// Backend can not list, fallback to treat target as an exact key.
+		exists, err := a.s.Exists(target)
+		if err != nil {
+			return nil, fmt.Errorf("check existence <%s>, %w", target, err)
+		}
+
+		if !exists {
+			return nil, fmt.Errorf("<%s>, %w", target, ErrNotFound)
+		}
+
+		entries = []common.FileEntry{{Path: target}}
+	} else if err != nil {
+		return nil, fmt.Errorf("list <%s>, %w", target, err)
+	}
+
+	// NOTICE: Only match on path boundaries, so that removing "key" never removes "key1".
+	// Signed manifests of removed archives are removed along with them.
+	dir := strings.TrimSuffix(target, "/") + "/"
+
+	var matched []common.FileEntry
+
+	for _, e := range entries {
+		if e.Path == target || e.Path == manifest.Path(target) || strings.HasPrefix(e.Path, dir) {
+			matched = append(matched, e)
+		}
+	}
+
+	if len(matched) == 0 {
+		return nil, fmt.Errorf("<%s>, %w", target, ErrNotFound)
+	}
+
+	if dryRun {
+		return matched, nil
+	}
+
+	for i, e := range matched {
+		level.Debug(a.logger).Log("msg", "removing object", "path", e.Path)
+
+		if err := a.s.Delete(e.Path); err != nil {
+			return matched[:i], fmt.Errorf("delete <%s>, %w", e.Path, err)
+		}
+	}
+
+	return matched, nil
+}
+
+// DiskUsage summarizes storage used under the given namespace, grouped by cache key.
+func (a *Admin) DiskUsage(namespace string) (Usage, error) {
+	prefix := strings.TrimSuffix(namespace, "/")
+	if prefix != "" {
+		prefix += "/"
+	}
+
+	entries, err := a.s.List(prefix)
+	if err != nil {
+		return Usage{}, fmt.Errorf("list <%s>, %w", prefix, err)
+	}
+
+	var (
+		usage = Usage{Namespace: namespace}
+		keys  = map[string]*KeyUsage{}
+	)
+
+	for _, e := range entries {
+		key := strings.SplitN(strings.TrimPrefix(e.Path, prefix), "/", 2)[0] // nolint:gomnd
+
+		ku, ok := keys[key]
+		if !ok {
+			ku = &KeyUsage{Key: key}
+			keys[key] = ku
+		}
+
+		ku.Objects++
+		ku.Size += e.Size
+
+		if e.LastModified.After(ku.LastModified) {
+			ku.LastModified = e.LastModified
+		}
+
+		usage.Objects++
+		usage.Size += e.Size
+	}
+
+	for _, ku := range keys {
+		usage.Keys = append(usage.Keys, *ku)
+	}
+
+	sort.Slice(usage.Keys, func(i, j int) bool {
+		if usage.Keys[i].Size == usage.Keys[j].Size {
+			return usage.Keys[i].Key < usage.Keys[j].Key
+		}
+
+		return usage.Keys[i].Size > usage.Keys[j].Size
+	})
+
+	return usage, nil
+}
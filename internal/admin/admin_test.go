@@ -0,0 +1,119 @@
+package admin
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/log"
+
+	"github.com/meltwater/drone-cache/archive"
+	"github.com/meltwater/drone-cache/storage"
+	"github.com/meltwater/drone-cache/storage/backend/filesystem"
+	"github.com/meltwater/drone-cache/storage/common"
+	"github.com/meltwater/drone-cache/test"
+)
+
+func TestAdmin(t *testing.T) {
+	t.Parallel()
+
+	adm, s, a := setup(t)
+
+	src, srcClean := test.CreateTempFilesInDir(t, "admin", []byte("hello\ngo!\n"), "") // 3 x 10 bytes
+	t.Cleanup(srcClean)
+
+	var buf bytes.Buffer
+	_, err := a.Create([]string{src}, &buf, false)
+	test.Ok(t, err)
+
+	for _, p := range []string{"ns/key/mount", "ns/key/other", "ns/key1/mount"} {
+		test.Ok(t, s.Put(p, bytes.NewReader(buf.Bytes())))
+	}
+
+	entries, err := adm.List("ns/")
+	test.Ok(t, err)
+	test.Equals(t, 3, len(entries))
+	test.Equals(t, "ns/key/mount", entries[0].Path)
+
+	arcEntries, err := adm.Inspect("ns/key/mount")
+	test.Ok(t, err)
+	test.Equals(t, 4, len(arcEntries)) // 1 x dir, 3 x file
+
+	var size int64
+	for _, e := range arcEntries {
+		size += e.Size
+	}
+
+	test.Equals(t, int64(30), size)
+
+	usage, err := adm.DiskUsage("ns")
+	test.Ok(t, err)
+	test.Equals(t, 3, usage.Objects)
+	test.Equals(t, 2, len(usage.Keys))
+	test.Equals(t, "key", usage.Keys[0].Key)
+	test.Equals(t, 2, usage.Keys[0].Objects)
+
+	removed, err := adm.Remove("ns/key", true)
+	test.Ok(t, err)
+	test.Equals(t, 2, len(removed))
+
+	removed, err = adm.Remove("ns/key", false)
+	test.Ok(t, err)
+	test.Equals(t, 2, len(removed))
+
+	entries, err = adm.List("ns/")
+	test.Ok(t, err)
+	test.Equals(t, 1, len(entries))
+	test.Equals(t, "ns/key1/mount", entries[0].Path)
+
+	_, err = adm.Remove("ns/key", false)
+	test.Assert(t, errors.Is(err, ErrNotFound), "expected not found error, got %v", err)
+
+	_, err = adm.Remove("", false)
+	test.Assert(t, errors.Is(err, ErrEmptyTarget), "expected empty target error, got %v", err)
+}
+
+func TestWrite(t *testing.T) {
+	t.Parallel()
+
+	usage := Usage{
+		Namespace: "ns",
+		Objects:   1,
+		Size:      2048,
+		Keys:      []KeyUsage{{Key: "key", Objects: 1, Size: 2048, LastModified: time.Unix(0, 0)}},
+	}
+
+	var table bytes.Buffer
+	test.Ok(t, Write(&table, OutputTable, usage))
+	test.Assert(t, strings.Contains(table.String(), "2.0 kB"), "table should contain human readable size: %s", table.String())
+
+	var js bytes.Buffer
+	test.Ok(t, Write(&js, OutputJSON, usage))
+	test.Assert(t, strings.Contains(js.String(), `"size_bytes": 2048`), "json should contain size: %s", js.String())
+
+	test.NotOk(t, Write(&js, "yaml", usage))
+
+	js.Reset()
+	test.Ok(t, Write(&js, OutputJSON, []common.FileEntry{{Path: "ns/key", Size: 2048}}))
+	test.Assert(t, strings.Contains(js.String(), `"path": "ns/key"`), "json should contain path: %s", js.String())
+	test.Assert(t, strings.Contains(js.String(), `"size_bytes": 2048`), "json should contain size: %s", js.String())
+}
+
+// Helpers
+
+func setup(t *testing.T) (*Admin, storage.Storage, archive.Archive) {
+	dir, cleanUp := test.CreateTempDir(t, "admin-test")
+	t.Cleanup(cleanUp)
+
+	b, err := filesystem.New(log.NewNopLogger(), filesystem.Config{CacheRoot: dir})
+	test.Ok(t, err)
+
+	s := storage.New(log.NewNopLogger(), b, time.Minute)
+
+	a, err := archive.FromFormat(log.NewNopLogger(), "", archive.Gzip)
+	test.Ok(t, err)
+
+	return New(log.NewNopLogger(), s, a), s, a
+}
@@ -0,0 +1,110 @@
+package admin
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"text/tabwriter"
+	"time"
+
+	"github.com/dustin/go-humanize"
+
+	arccommon "github.com/meltwater/drone-cache/archive/common"
+	"github.com/meltwater/drone-cache/storage/common"
+)
+
+const (
+	// OutputTable renders results as human readable tables.
+	OutputTable = "table"
+	// OutputJSON renders results as JSON documents.
+	OutputJSON = "json"
+
+	// DefaultOutput is the output format used unless specified otherwise.
+	DefaultOutput = OutputTable
+)
+
+// fileEntry is the JSON document of a stored object, its fields are named like the ones of Usage.
+// NOTICE: common.FileEntry is decoded from responses of the harness API, its field names can not change.
+type fileEntry struct {
+	Path         string    `json:"path"`
+	Size         int64     `json:"size_bytes"`
+	LastModified time.Time `json:"last_modified"`
+}
+
+// Write renders given result to the writer using the given output format.
+func Write(w io.Writer, format string, v interface{}) error {
+	switch format {
+	case OutputJSON:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+
+		if entries, ok := v.([]common.FileEntry); ok {
+			docs := make([]fileEntry, 0, len(entries))
+			for _, e := range entries {
+				docs = append(docs, fileEntry(e))
+			}
+
+			return enc.Encode(docs)
+		}
+
+		return enc.Encode(v)
+	case OutputTable:
+		return writeTable(w, v)
+	default:
+		return fmt.Errorf("unknown output format <%s>, (%s, %s)", format, OutputTable, OutputJSON)
+	}
+}
+
+func writeTable(w io.Writer, v interface{}) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0) // nolint:gomnd
+
+	switch res := v.(type) {
+	case []common.FileEntry:
+		fmt.Fprintln(tw, "PATH\tSIZE\tLAST MODIFIED")
+
+		var total int64
+
+		for _, e := range res {
+			fmt.Fprintf(tw, "%s\t%s\t%s\n", e.Path, humanize.Bytes(uint64(e.Size)), formatTime(e.LastModified))
+			total += e.Size
+		}
+
+		fmt.Fprintf(tw, "TOTAL (%d)\t%s\t\n", len(res), humanize.Bytes(uint64(total)))
+	case []arccommon.Entry:
+		fmt.Fprintln(tw, "MODE\tSIZE\tMODIFIED\tNAME")
+
+		var total int64
+
+		for _, e := range res {
+			name := e.Name
+			if e.Linkname != "" {
+				name += " -> " + e.Linkname
+			}
+
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", e.Mode, humanize.Bytes(uint64(e.Size)), formatTime(e.ModTime), name)
+			total += e.Size
+		}
+
+		fmt.Fprintf(tw, "TOTAL (%d)\t%s\t\t\n", len(res), humanize.Bytes(uint64(total)))
+	case Usage:
+		fmt.Fprintln(tw, "KEY\tOBJECTS\tSIZE\tLAST MODIFIED")
+
+		for _, k := range res.Keys {
+			fmt.Fprintf(tw, "%s\t%d\t%s\t%s\n", k.Key, k.Objects, humanize.Bytes(uint64(k.Size)), formatTime(k.LastModified))
+		}
+
+		fmt.Fprintf(tw, "TOTAL\t%d\t%s\t\n", res.Objects, humanize.Bytes(uint64(res.Size)))
+	default:
+		return fmt.Errorf("can not render <%T> as table", v)
+	}
+
+	return tw.Flush()
+}
+
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return "-"
+	}
+
+	return t.UTC().Format(time.RFC3339)
+}
@@ -15,14 +15,15 @@ type (
 
 	// Build stores information about current build.
 	Build struct {
-		Created  int64
-		Deploy   string
-		Event    string
-		Finished int64
-		Link     string
-		Number   int
-		Started  int64
-		Status   string
+		Created     int64
+		Deploy      string
+		Event       string
+		Finished    int64
+		Link        string
+		Number      int
+		PullRequest int
+		Started     int64
+		Status      string
 	}
 
 	// Commit stores information about current commit.
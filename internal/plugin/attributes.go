@@ -0,0 +1,38 @@
+package plugin
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/meltwater/drone-cache/archive/common"
+)
+
+// AttributesConfig configures how owners, modes and modification times of restored files are restored.
+type AttributesConfig struct {
+	PreserveOwner   bool
+	ModeMask        string // octal, e.g. 022
+	PreserveModTime bool
+}
+
+// attributes parses the configured attributes.
+func (c AttributesConfig) attributes() (common.Attributes, error) {
+	var mask uint64
+
+	if c.ModeMask != "" {
+		var err error
+		if mask, err = strconv.ParseUint(c.ModeMask, 8, 32); err != nil {
+			return common.Attributes{}, fmt.Errorf("parse mode mask, %w", err)
+		}
+
+		if mask > uint64(os.ModePerm) {
+			return common.Attributes{}, fmt.Errorf("mode mask <%s> is not made of permission bits", c.ModeMask)
+		}
+	}
+
+	return common.Attributes{
+		PreserveOwner:   c.PreserveOwner,
+		ModeMask:        os.FileMode(mask),
+		PreserveModTime: c.PreserveModTime,
+	}, nil
+}
@@ -0,0 +1,25 @@
+package plugin
+
+import (
+	"testing"
+
+	"github.com/meltwater/drone-cache/archive/common"
+	"github.com/meltwater/drone-cache/test"
+)
+
+func TestAttributes(t *testing.T) {
+	t.Parallel()
+
+	a, err := AttributesConfig{}.attributes()
+	test.Ok(t, err)
+	test.Equals(t, common.Attributes{}, a)
+
+	a, err = AttributesConfig{PreserveOwner: true, ModeMask: "027", PreserveModTime: true}.attributes()
+	test.Ok(t, err)
+	test.Equals(t, common.Attributes{PreserveOwner: true, ModeMask: 0027, PreserveModTime: true}, a)
+
+	for _, mask := range []string{"rw", "9", "1777"} {
+		_, err = AttributesConfig{ModeMask: mask}.attributes()
+		test.NotOk(t, err)
+	}
+}
@@ -3,18 +3,46 @@ package autodetect
 import (
 	"crypto/md5" // #nosec
 	"encoding/hex"
+	"fmt"
 	"io"
 	"os"
 	"path/filepath"
+	"strings"
 )
 
+// gradleHashFiles are the wrapper properties and version catalog of Gradle builds.
+var gradleHashFiles = []string{
+	filepath.Join("gradle", "wrapper", "gradle-wrapper.properties"),
+	filepath.Join("gradle", "libs.versions.toml"),
+}
+
 type buildToolInfo struct {
 	globToDetect string
 	tool         string
 	preparer     RepoPreparer
+	// hashFiles are files next to the detected one, that are hashed into the key as well if they exist.
+	hashFiles []string
+	// skipIfExists are files next to the detected one, that are detected by other tools, the project is skipped if one exists.
+	skipIfExists []string
 }
 
-func DetectDirectoriesToCache(skipPrepare bool) ([]string, []string, string, error) {
+func DetectDirectoriesToCache(skipPrepare bool, opts ...Option) ([]string, []string, string, error) {
+	options := options{maxDepth: DefaultMaxDepth, rulesFile: DefaultRulesFile}
+
+	for _, o := range opts {
+		o.apply(&options)
+	}
+
+	var store bool
+
+	switch options.nodeCache {
+	case "", NodeModules:
+	case NodeStore:
+		store = true
+	default:
+		return nil, nil, "", fmt.Errorf("unknown node cache <%s>, use %s or %s", options.nodeCache, NodeModules, NodeStore)
+	}
+
 	var buildToolInfoMapping = []buildToolInfo{
 		{
 			globToDetect: "pom.xml",
@@ -25,21 +53,41 @@ func DetectDirectoriesToCache(skipPrepare bool) ([]string, []string, string, err
 			globToDetect: "build.gradle",
 			tool:         "gradle",
 			preparer:     newGradlePreparer(),
+			hashFiles:    gradleHashFiles,
+		},
+		{
+			globToDetect: "*.gradle.kts",
+			tool:         "gradle",
+			preparer:     newGradlePreparer(),
+			hashFiles:    gradleHashFiles,
+		},
+		{
+			globToDetect: "build.sbt",
+			tool:         "sbt",
+			preparer:     newSbtPreparer(),
+			hashFiles:    []string{filepath.Join("project", "build.properties"), filepath.Join("project", "plugins.sbt")},
 		},
 		{
 			globToDetect: "WORKSPACE",
 			tool:         "bazel",
-			preparer:     newBazelPreparer(),
+			preparer:     newBazelPreparer(options.bazelDiskCacheSize),
+			hashFiles:    []string{".bazelversion"},
 		},
 		{
 			globToDetect: "MODULE.bazel",
 			tool:         "bazel",
-			preparer:     newBzlmodPreparer(),
+			preparer:     newBzlmodPreparer(options.bazelDiskCacheSize),
+			hashFiles:    []string{"MODULE.bazel.lock", ".bazelversion"},
 		},
 		{
-			globToDetect: "package.json",
-			tool:         "node",
-			preparer:     newNodePreparer(),
+			globToDetect: "pnpm-lock.yaml",
+			tool:         "pnpm",
+			preparer:     newPnpmPreparer(store),
+		},
+		{
+			globToDetect: "package-lock.json",
+			tool:         "npm",
+			preparer:     newNpmPreparer(store),
 		},
 		{
 			globToDetect: "yarn.lock",
@@ -49,7 +97,8 @@ func DetectDirectoriesToCache(skipPrepare bool) ([]string, []string, string, err
 		{
 			globToDetect: "go.mod",
 			tool:         "golang",
-			preparer:     newGoPreparer(),
+			preparer:     newGoPreparer(options.goCacheTrim),
+			hashFiles:    []string{"go.sum"},
 		},
 		{
 			globToDetect: "*.csproj",
@@ -66,6 +115,64 @@ func DetectDirectoriesToCache(skipPrepare bool) ([]string, []string, string, err
 			tool:         "dotnet",
 			preparer:     newDotnetPreparer(),
 		},
+		{
+			globToDetect: "poetry.lock",
+			tool:         "poetry",
+			preparer:     newPoetryPreparer(),
+		},
+		{
+			globToDetect: "Pipfile.lock",
+			tool:         "pipenv",
+			preparer:     newPipenvPreparer(),
+		},
+		{
+			globToDetect: "uv.lock",
+			tool:         "uv",
+			preparer:     newUvPreparer(),
+		},
+		{
+			globToDetect: "requirements*.txt",
+			tool:         "pip",
+			preparer:     newPipPreparer(),
+		},
+		{
+			globToDetect: "pyproject.toml",
+			tool:         "python",
+			preparer:     newPyprojectPreparer(),
+			skipIfExists: []string{"poetry.lock", "uv.lock", "Pipfile.lock"},
+		},
+		{
+			globToDetect: "Cargo.toml",
+			tool:         "cargo",
+			preparer:     newCargoPreparer(options.cargoTarget),
+			hashFiles:    []string{"Cargo.lock", "rust-toolchain.toml", "rust-toolchain"},
+		},
+		{
+			globToDetect: "Gemfile.lock",
+			tool:         "bundler",
+			preparer:     newBundlerPreparer(),
+		},
+		{
+			globToDetect: "composer.lock",
+			tool:         "composer",
+			preparer:     newComposerPreparer(),
+		},
+	}
+
+	rules, err := readRules(options.rulesFile)
+	if err != nil {
+		return nil, nil, "", err
+	}
+
+	buildToolInfoMapping = append(buildToolInfoMapping, rules...)
+
+	if !store {
+		// NOTICE: Yarn caches are configured regardless, node_modules is cached along with them.
+		buildToolInfoMapping = append(buildToolInfoMapping, buildToolInfo{
+			globToDetect: "yarn.lock",
+			tool:         "yarn",
+			preparer:     newNodePreparer(),
+		})
 	}
 
 	var directoriesToCache []string
@@ -74,30 +181,71 @@ func DetectDirectoriesToCache(skipPrepare bool) ([]string, []string, string, err
 
 	var hashes string
 
+	// hashed holds detected build files that are already hashed into the key, by their path.
+	hashed := map[string]bool{}
+
 	for _, supportedTool := range buildToolInfoMapping {
-		hash, dir, err := hashIfFileExist(supportedTool.globToDetect)
+		projects, err := findProjects(supportedTool.globToDetect, options.maxDepth, options.excludes)
 		if err != nil {
 			return nil, nil, "", err
 		}
-		if hash == "" {
-			hash, dir, err = hashIfFileExist(filepath.Join("**", supportedTool.globToDetect))
+
+		var projectHashes []string
+
+		for _, buildFiles := range projects {
+			if anyExists(filepath.Dir(buildFiles[0]), supportedTool.skipIfExists) {
+				continue
+			}
+
+			var (
+				hash, dir string
+				unhashed  bool
+			)
+
+			for _, buildFile := range buildFiles {
+				fileHash, fileDir, err := calculateMd5FromFiles([]string{buildFile})
+				if err != nil {
+					return nil, nil, "", err
+				}
+
+				hash += fileHash
+				dir = fileDir
+				unhashed = unhashed || !hashed[buildFile]
+			}
+
+			extra, err := hashFilesIfExist(dir, supportedTool.hashFiles)
 			if err != nil {
 				return nil, nil, "", err
 			}
-		}
-		if err != nil {
-			return nil, nil, "", err
-		}
-		if hash != "" && !skipPrepare {
+
+			hash += extra
+
+			if skipPrepare {
+				continue
+			}
+
 			dirToCache, err := supportedTool.preparer.PrepareRepo(dir)
 			if err != nil {
 				return nil, nil, "", err
 			}
 
-			directoriesToCache = appendIfMissing(directoriesToCache, dirToCache)
+			// NOTICE: Preparers return no directory if the project configures every cached directory itself.
+			if dirToCache != "" {
+				directoriesToCache = appendIfMissing(directoriesToCache, dirToCache)
+			}
+
 			buildToolsDetected = appendIfMissing(buildToolsDetected, supportedTool.tool)
-			hashes += hash
+
+			if unhashed {
+				projectHashes = append(projectHashes, hash)
+			}
+
+			for _, buildFile := range buildFiles {
+				hashed[buildFile] = true
+			}
 		}
+
+		hashes += combineHashes(projectHashes)
 	}
 
 	return directoriesToCache, buildToolsDetected, hashes, nil
@@ -112,14 +260,47 @@ func appendIfMissing(slice []string, elem string) []string {
 	return append(slice, elem)
 }
 
-func hashIfFileExist(glob string) (string, string, error) {
-	matches, _ := filepath.Glob(glob)
+// combineHashes returns the hash of a single project as is, and a hash of the hashes of multiple projects.
+func combineHashes(hashes []string) string {
+	if len(hashes) <= 1 {
+		return strings.Join(hashes, "")
+	}
+
+	hash := md5.New() // #nosec
+	for _, h := range hashes {
+		io.WriteString(hash, h) //nolint:errcheck
+	}
+
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+func anyExists(dir string, fileNames []string) bool {
+	for _, fileName := range fileNames {
+		if fileExists(filepath.Join(dir, fileName)) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func hashFilesIfExist(dir string, fileNames []string) (string, error) {
+	var hashes string
+
+	for _, fileName := range fileNames {
+		if _, err := os.Stat(filepath.Join(dir, fileName)); err != nil {
+			continue
+		}
+
+		hash, _, err := calculateMd5FromFiles([]string{filepath.Join(dir, fileName)})
+		if err != nil {
+			return "", err
+		}
 
-	if len(matches) == 0 {
-		return "", "", nil
+		hashes += hash
 	}
 
-	return calculateMd5FromFiles(matches)
+	return hashes, nil
 }
 
 func calculateMd5FromFiles(fileList []string) (string, string, error) {
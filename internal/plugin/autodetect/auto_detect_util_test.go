@@ -121,3 +121,114 @@ func TestDetectDirectoriesToCacheCombined(t *testing.T) {
 	test.Equals(t, buildToolsDetected, expectedDetectedTool)
 	test.Equals(t, hashes, "1eb00e74bffac0c4fa2d6dbfd8c26cb7baab6c16d9143523b7865d46896e4596")
 }
+
+func TestDetectDirectoriesToCacheCargo(t *testing.T) {
+	test.Ok(t, os.WriteFile("Cargo.toml", []byte(testFileContent), 0644))
+	test.Ok(t, os.WriteFile("Cargo.lock", []byte(testFileContent2), 0644))
+
+	t.Cleanup(func() {
+		os.Remove("Cargo.toml")
+		os.Remove("Cargo.lock")
+		os.RemoveAll(".cargo")
+	})
+
+	directoriesToCache, buildToolsDetected, hashes, err := DetectDirectoriesToCache(false, WithCargoTarget(true))
+	test.Ok(t, err)
+
+	path, _ := filepath.Abs(".cargo-cache")
+
+	test.Equals(t, directoriesToCache, []string{path})
+	test.Equals(t, buildToolsDetected, []string{"cargo"})
+	test.Equals(t, hashes, "baab6c16d9143523b7865d46896e45961eb00e74bffac0c4fa2d6dbfd8c26cb7")
+}
+
+func TestDetectDirectoriesToCacheSbtConfiguredByProject(t *testing.T) {
+	test.Ok(t, os.WriteFile("build.sbt", []byte(testFileContent), 0644))
+	test.Ok(t, os.WriteFile(".sbtopts", []byte("-Dsbt.global.base=/opt/sbt\n-Dsbt.boot.directory=/opt/sbt/boot\n"+
+		"-Dsbt.ivy.home=/opt/ivy2\n-Dsbt.coursier.home=/opt/coursier\n"), 0644))
+
+	t.Cleanup(func() {
+		os.Remove("build.sbt")
+		os.Remove(".sbtopts")
+	})
+
+	directoriesToCache, buildToolsDetected, _, err := DetectDirectoriesToCache(false)
+	test.Ok(t, err)
+
+	test.Equals(t, 0, len(directoriesToCache))
+	test.Equals(t, buildToolsDetected, []string{"sbt"})
+}
+
+func TestDetectDirectoriesToCachePyprojectWithLockFile(t *testing.T) {
+	test.Ok(t, os.WriteFile("pyproject.toml", []byte(testFileContent2), 0644))
+	test.Ok(t, os.WriteFile("poetry.lock", []byte(testFileContent), 0644))
+
+	t.Cleanup(func() {
+		os.Remove("pyproject.toml")
+		os.Remove("poetry.lock")
+		os.Remove("poetry.toml")
+	})
+
+	directoriesToCache, buildToolsDetected, hashes, err := DetectDirectoriesToCache(false)
+	test.Ok(t, err)
+
+	path, _ := filepath.Abs(filepath.Join(".cache", "pypoetry"))
+
+	test.Equals(t, directoriesToCache, []string{path})
+	test.Equals(t, buildToolsDetected, []string{"poetry"})
+	// Only the lock file is hashed into the key, so that version bumps in pyproject.toml keep the cache.
+	test.Equals(t, hashes, "baab6c16d9143523b7865d46896e4596")
+}
+
+func TestDetectDirectoriesToCacheNode(t *testing.T) {
+	test.Ok(t, os.WriteFile("package.json", []byte(testFileContent2), 0644))
+	test.Ok(t, os.WriteFile("yarn.lock", []byte(testFileContent), 0644))
+
+	t.Cleanup(func() {
+		os.Remove("package.json")
+		os.Remove("yarn.lock")
+		os.Remove(".yarnrc")
+		os.Remove(".yarnrc.yaml")
+	})
+
+	yarnDir, _ := filepath.Abs(".yarn")
+	nodeModulesDir, _ := filepath.Abs("node_modules")
+
+	for _, tc := range []struct {
+		nodeCache string
+		expected  []string
+	}{
+		{nodeCache: NodeModules, expected: []string{yarnDir, nodeModulesDir}},
+		{nodeCache: NodeStore, expected: []string{yarnDir}},
+	} {
+		directoriesToCache, buildToolsDetected, hashes, err := DetectDirectoriesToCache(false, WithNodeCache(tc.nodeCache))
+		test.Ok(t, err)
+
+		test.Equals(t, directoriesToCache, tc.expected)
+		test.Equals(t, buildToolsDetected, []string{"yarn"})
+		// Only the lock file is hashed into the key, once.
+		test.Equals(t, hashes, "baab6c16d9143523b7865d46896e4596")
+	}
+
+	_, _, _, err := DetectDirectoriesToCache(false, WithNodeCache("unknown"))
+	test.NotOk(t, err)
+}
+
+func TestDetectDirectoriesToCacheGradleKotlin(t *testing.T) {
+	test.Ok(t, os.WriteFile("settings.gradle.kts", []byte(testFileContent), 0644))
+	test.Ok(t, os.MkdirAll(filepath.Join("gradle", "wrapper"), 0755))
+	test.Ok(t, os.WriteFile(filepath.Join("gradle", "libs.versions.toml"), []byte(testFileContent2), 0644))
+
+	t.Cleanup(func() {
+		os.Remove("settings.gradle.kts")
+		os.RemoveAll("gradle")
+		os.Remove("gradle.properties")
+	})
+
+	directoriesToCache, buildToolsDetected, hashes, err := DetectDirectoriesToCache(false)
+	test.Ok(t, err)
+
+	test.Equals(t, directoriesToCache, []string{toolGradleDir})
+	test.Equals(t, buildToolsDetected, []string{toolGradle})
+	test.Equals(t, hashes, "baab6c16d9143523b7865d46896e45961eb00e74bffac0c4fa2d6dbfd8c26cb7")
+}
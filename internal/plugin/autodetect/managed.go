@@ -0,0 +1,211 @@
+package autodetect
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/meltwater/drone-cache/internal"
+)
+
+// StateFile is the name of the file that records the original content of configuration files changed by preparers.
+const StateFile = ".drone-cache-autodetect.json"
+
+const (
+	beginMarker = "BEGIN drone-cache autodetect"
+	endMarker   = "END drone-cache autodetect"
+)
+
+// Variable for testing - allows mocking os.OpenFile
+var osOpenFile = os.OpenFile
+
+// state holds the original content of changed configuration files by their absolute path,
+// nil for files that did not exist.
+type state struct {
+	Files map[string]*string `json:"files"`
+}
+
+// setManagedBlock writes given lines into the block of given name of a configuration file,
+// the block is delimited by comments with given prefix and replaced in place if it already exists.
+func setManagedBlock(fileName, comment, name, lines string) error {
+	content, err := os.ReadFile(fileName)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+
+	begin := fmt.Sprintf("%s %s %s\n", comment, beginMarker, name)
+	end := fmt.Sprintf("%s %s %s\n", comment, endMarker, name)
+	block := begin + strings.TrimSuffix(lines, "\n") + "\n" + end
+
+	existing := string(content)
+
+	var updated string
+
+	if i := strings.Index(existing, begin); i >= 0 {
+		j := strings.Index(existing[i:], end)
+		if j < 0 {
+			return fmt.Errorf("unterminated managed block <%s> in <%s>", name, fileName)
+		}
+
+		updated = existing[:i] + block + existing[i+j+len(end):]
+	} else {
+		if existing != "" && !strings.HasSuffix(existing, "\n") {
+			existing += "\n"
+		}
+
+		updated = existing + block
+	}
+
+	if updated == string(content) {
+		return nil
+	}
+
+	return writeConfig(fileName, []byte(updated))
+}
+
+// withoutManagedBlock returns given content without the block of given name, so that settings of the project
+// can be told apart from the ones written by preparers.
+func withoutManagedBlock(content, comment, name string) string {
+	begin := fmt.Sprintf("%s %s %s\n", comment, beginMarker, name)
+	end := fmt.Sprintf("%s %s %s\n", comment, endMarker, name)
+
+	i := strings.Index(content, begin)
+	if i < 0 {
+		return content
+	}
+
+	j := strings.Index(content[i:], end)
+	if j < 0 {
+		return content[:i]
+	}
+
+	return content[:i] + content[i+j+len(end):]
+}
+
+// writeConfig writes given content to a configuration file, after recording its original content.
+func writeConfig(fileName string, content []byte) (err error) {
+	if err := recordOriginal(fileName); err != nil {
+		return fmt.Errorf("record original content of <%s>, %w", fileName, err)
+	}
+
+	f, err := osOpenFile(fileName, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644) //nolint:gomnd
+	if err != nil {
+		return err
+	}
+	defer internal.CloseWithErrCapturef(&err, f, "close <%s>", fileName)
+
+	_, err = f.Write(content)
+
+	return err
+}
+
+// recordOriginal records the content of given file in the state file, unless it is already recorded.
+func recordOriginal(fileName string) error {
+	path, err := filepath.Abs(fileName)
+	if err != nil {
+		return err
+	}
+
+	s, err := readState()
+	if err != nil {
+		return err
+	}
+
+	if _, ok := s.Files[path]; ok {
+		return nil
+	}
+
+	content, err := os.ReadFile(path)
+
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		s.Files[path] = nil
+	case err != nil:
+		return err
+	default:
+		original := string(content)
+		s.Files[path] = &original
+	}
+
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(stateFile(), data, 0644) //nolint:gomnd
+}
+
+// stateFile returns the path of the state file relative to the workspace. It is kept in the .git directory if there
+// is one, so that it is not reported as a change of the repository, and in the workspace itself otherwise.
+func stateFile() string {
+	if fi, err := os.Stat(".git"); err == nil && fi.IsDir() {
+		return filepath.Join(".git", StateFile)
+	}
+
+	return StateFile
+}
+
+func readState() (*state, error) {
+	s := &state{Files: map[string]*string{}}
+
+	data, err := os.ReadFile(stateFile())
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(data, s); err != nil {
+		return nil, fmt.Errorf("parse state file <%s>, %w", stateFile(), err)
+	}
+
+	if s.Files == nil {
+		s.Files = map[string]*string{}
+	}
+
+	return s, nil
+}
+
+// Cleanup restores configuration files changed by preparers to their original content, removes the files they created
+// and the state file. It returns the restored files.
+func Cleanup() ([]string, error) {
+	s, err := readState()
+	if err != nil {
+		return nil, err
+	}
+
+	var restored []string
+
+	for path, original := range s.Files {
+		if original == nil {
+			if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+				return restored, err
+			}
+
+			// Directories created for the file, like .mvn, are removed as well if they are empty.
+			os.Remove(filepath.Dir(path)) //nolint:errcheck
+		} else if err := os.WriteFile(path, []byte(*original), 0644); err != nil { //nolint:gomnd
+			if errors.Is(err, os.ErrNotExist) { // the project was removed meanwhile
+				continue
+			}
+
+			return restored, err
+		}
+
+		restored = append(restored, path)
+	}
+
+	sort.Strings(restored)
+
+	if err := os.Remove(stateFile()); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return restored, err
+	}
+
+	return restored, nil
+}
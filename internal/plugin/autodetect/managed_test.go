@@ -0,0 +1,121 @@
+package autodetect
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/meltwater/drone-cache/test"
+)
+
+func TestMain(m *testing.M) {
+	code := m.Run()
+
+	// Restore configuration files changed by tests that prepare the package directory.
+	if _, err := Cleanup(); err != nil {
+		code = 1
+	}
+
+	os.Exit(code)
+}
+
+func TestSetManagedBlock(t *testing.T) {
+	tempDir, err := os.MkdirTemp("", "managed-test")
+	test.Ok(t, err)
+	defer os.RemoveAll(tempDir)
+
+	fileName := filepath.Join(tempDir, ".bazelrc")
+	test.Ok(t, os.WriteFile(fileName, []byte("build --jobs=4"), 0644))
+
+	test.Ok(t, setManagedBlock(fileName, "#", "bazel", "build --test_tmpdir=a\n"))
+	test.Ok(t, setManagedBlock(fileName, "#", "bzlmod", "build --module_cache=b"))
+
+	expected := "build --jobs=4\n" +
+		"# BEGIN drone-cache autodetect bazel\nbuild --test_tmpdir=a\n# END drone-cache autodetect bazel\n" +
+		"# BEGIN drone-cache autodetect bzlmod\nbuild --module_cache=b\n# END drone-cache autodetect bzlmod\n"
+
+	content, err := os.ReadFile(fileName)
+	test.Ok(t, err)
+	test.Equals(t, expected, string(content))
+
+	// Blocks are updated in place.
+	test.Ok(t, setManagedBlock(fileName, "#", "bazel", "build --test_tmpdir=c"))
+	test.Ok(t, setManagedBlock(fileName, "#", "bzlmod", "build --module_cache=b"))
+
+	content, err = os.ReadFile(fileName)
+	test.Ok(t, err)
+	test.Equals(t, strings.Replace(expected, "tmpdir=a", "tmpdir=c", 1), string(content))
+
+	test.Ok(t, os.WriteFile(fileName, []byte("# BEGIN drone-cache autodetect bazel\n"), 0644))
+	test.NotOk(t, setManagedBlock(fileName, "#", "bazel", "build --test_tmpdir=a"))
+}
+
+func TestCleanup(t *testing.T) {
+	tempDir, err := os.MkdirTemp("", "cleanup-test")
+	test.Ok(t, err)
+	defer os.RemoveAll(tempDir)
+
+	wd, err := os.Getwd()
+	test.Ok(t, err)
+	test.Ok(t, os.Chdir(tempDir))
+	t.Cleanup(func() { os.Chdir(wd) }) //nolint:errcheck
+
+	original := "org.gradle.jvmargs=-Xmx2g\n"
+	test.Ok(t, os.WriteFile("gradle.properties", []byte(original), 0644))
+	test.Ok(t, os.WriteFile(pomFile, []byte(testFileContent), 0644))
+
+	// Preparing twice records the original content once.
+	for i := 0; i < 2; i++ {
+		_, err = newGradlePreparer().PrepareRepo(tempDir)
+		test.Ok(t, err)
+
+		_, err = newMavenPreparer().PrepareRepo(tempDir)
+		test.Ok(t, err)
+	}
+
+	_, err = os.Stat(StateFile)
+	test.Ok(t, err)
+
+	restored, err := Cleanup()
+	test.Ok(t, err)
+	test.Equals(t, []string{filepath.Join(tempDir, ".mvn", "maven.config"), filepath.Join(tempDir, "gradle.properties")}, restored)
+
+	content, err := os.ReadFile("gradle.properties")
+	test.Ok(t, err)
+	test.Equals(t, original, string(content))
+
+	for _, removed := range []string{".mvn", StateFile} {
+		_, err = os.Stat(removed)
+		test.Assert(t, os.IsNotExist(err), "%s should be removed", removed)
+	}
+}
+
+func TestCleanupWithGitDirectory(t *testing.T) {
+	tempDir, err := os.MkdirTemp("", "cleanup-test")
+	test.Ok(t, err)
+	defer os.RemoveAll(tempDir)
+
+	wd, err := os.Getwd()
+	test.Ok(t, err)
+	test.Ok(t, os.Chdir(tempDir))
+	t.Cleanup(func() { os.Chdir(wd) }) //nolint:errcheck
+
+	test.Ok(t, os.Mkdir(".git", 0755))
+
+	_, err = newGradlePreparer().PrepareRepo(tempDir)
+	test.Ok(t, err)
+
+	_, err = os.Stat(filepath.Join(".git", StateFile))
+	test.Ok(t, err)
+
+	_, err = os.Stat(StateFile)
+	test.Assert(t, os.IsNotExist(err), "state file should not be in the workspace")
+
+	restored, err := Cleanup()
+	test.Ok(t, err)
+	test.Equals(t, []string{filepath.Join(tempDir, "gradle.properties")}, restored)
+
+	_, err = os.Stat(filepath.Join(".git", StateFile))
+	test.Assert(t, os.IsNotExist(err), "state file should be removed")
+}
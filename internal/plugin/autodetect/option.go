@@ -0,0 +1,78 @@
+package autodetect
+
+import "time"
+
+type options struct {
+	cargoTarget bool
+	nodeCache   string
+	maxDepth    int
+	excludes    []string
+	goCacheTrim time.Duration
+	rulesFile   string
+
+	bazelDiskCacheSize int64
+}
+
+// Option overrides behavior of auto detection.
+type Option interface {
+	apply(*options)
+}
+
+type optionFunc func(*options)
+
+func (f optionFunc) apply(o *options) {
+	f(o)
+}
+
+// WithCargoTarget sets whether the target directory of Cargo builds is cached along with their dependencies.
+func WithCargoTarget(b bool) Option {
+	return optionFunc(func(o *options) {
+		o.cargoTarget = b
+	})
+}
+
+// WithNodeCache sets what is cached for Node projects, either NodeModules or NodeStore.
+func WithNodeCache(s string) Option {
+	return optionFunc(func(o *options) {
+		o.nodeCache = s
+	})
+}
+
+// WithMaxDepth sets the number of directories projects are searched in below the workspace, 0 keeps DefaultMaxDepth.
+func WithMaxDepth(i int) Option {
+	return optionFunc(func(o *options) {
+		if i > 0 {
+			o.maxDepth = i
+		}
+	})
+}
+
+// WithExcludes sets patterns of directories that are not searched for projects.
+func WithExcludes(patterns []string) Option {
+	return optionFunc(func(o *options) {
+		o.excludes = patterns
+	})
+}
+
+// WithGoCacheTrim sets the age of unused Go build cache entries that are removed before caching, 0 keeps every entry.
+func WithGoCacheTrim(d time.Duration) Option {
+	return optionFunc(func(o *options) {
+		o.goCacheTrim = d
+	})
+}
+
+// WithRulesFile sets the configuration file that declares additional rules, an empty name keeps DefaultRulesFile.
+func WithRulesFile(fileName string) Option {
+	return optionFunc(func(o *options) {
+		if fileName != "" {
+			o.rulesFile = fileName
+		}
+	})
+}
+
+// WithBazelDiskCacheSize sets the size in bytes the Bazel disk cache is trimmed to before caching, 0 keeps every entry.
+func WithBazelDiskCacheSize(i int64) Option {
+	return optionFunc(func(o *options) {
+		o.bazelDiskCacheSize = i
+	})
+}
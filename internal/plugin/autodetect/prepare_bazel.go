@@ -1,49 +1,104 @@
 package autodetect
 
 import (
-	"errors"
 	"fmt"
+	"io/fs"
 	"os"
 	"path/filepath"
+	"sort"
 )
 
-type bazelPreparer struct{}
+type bazelPreparer struct {
+	// diskCacheSize is the size the disk cache is trimmed to, if positive.
+	diskCacheSize int64
+}
 
-func newBazelPreparer() *bazelPreparer {
-	return &bazelPreparer{}
+func newBazelPreparer(diskCacheSize int64) *bazelPreparer {
+	return &bazelPreparer{diskCacheSize: diskCacheSize}
 }
 
-func (*bazelPreparer) PrepareRepo(dir string) (string, error) {
+func (p *bazelPreparer) PrepareRepo(dir string) (string, error) {
 	fileName := filepath.Join(dir, ".bazelrc")
 	pathToCache := filepath.Join(dir, ".bazel")
-	cmdToOverrideRepo := fmt.Sprintf("build --test_tmpdir=%s\ntest --test_tmpdir=%s", pathToCache, pathToCache)
+	cmdToOverrideRepo := fmt.Sprintf("build --test_tmpdir=%s\ntest --test_tmpdir=%s\n%s",
+		pathToCache, pathToCache, bazelCaches(pathToCache))
+
+	if err := setManagedBlock(fileName, "#", "bazel", cmdToOverrideRepo); err != nil {
+		return "", err
+	}
+
+	if err := trimBazelDiskCache(filepath.Join(pathToCache, "disk"), p.diskCacheSize); err != nil {
+		return "", fmt.Errorf("trim bazel disk cache, %w", err)
+	}
+
+	return pathToCache, nil
+}
+
+// bazelCaches returns the options that relocate the repository and disk caches of Bazel into given directory.
+func bazelCaches(pathToCache string) string {
+	return fmt.Sprintf("build --repository_cache=%s\nbuild --disk_cache=%s\n",
+		filepath.Join(pathToCache, "repository"), filepath.Join(pathToCache, "disk"))
+}
+
+// trimBazelDiskCache removes the least recently used files of given disk cache, until it is not bigger than given size.
+// NOTICE: Bazel updates modification times of disk cache entries when they are used.
+func trimBazelDiskCache(diskCache string, size int64) error {
+	if size <= 0 {
+		return nil
+	}
+
+	type entry struct {
+		path string
+		fi   fs.FileInfo
+	}
 
-	if _, err := os.Stat(fileName); errors.Is(err, os.ErrNotExist) {
-		f, err := os.Create(fileName)
+	var (
+		entries []entry
+		total   int64
+	)
+
+	err := filepath.WalkDir(diskCache, func(path string, d fs.DirEntry, err error) error {
 		if err != nil {
-			return "", err
+			return err
+		}
+
+		if !d.Type().IsRegular() {
+			return nil
 		}
-		defer f.Close()
-		_, err = f.WriteString(cmdToOverrideRepo)
 
+		fi, err := d.Info()
 		if err != nil {
-			return "", err
+			return err
 		}
 
-		return pathToCache, nil
-	}
+		entries = append(entries, entry{path, fi})
+		total += fi.Size()
 
-	f, err := os.OpenFile(fileName, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644) //nolint:gomnd
+		return nil
+	})
+	if os.IsNotExist(err) {
+		return nil
+	}
 
 	if err != nil {
-		return "", err
+		return err
 	}
-	defer f.Close()
-	_, err = f.WriteString(cmdToOverrideRepo)
 
-	if err != nil {
-		return "", err
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].fi.ModTime().Before(entries[j].fi.ModTime())
+	})
+
+	for _, e := range entries {
+		if total <= size {
+			break
+		}
+
+		if err := os.Remove(e.path); err != nil {
+			return err
+		}
+
+		total -= e.fi.Size()
 	}
 
-	return pathToCache, nil
+	return nil
 }
@@ -0,0 +1,56 @@
+package autodetect
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/meltwater/drone-cache/test"
+)
+
+func TestBazelPreparerPrepareRepo(t *testing.T) {
+	tempDir, err := os.MkdirTemp("", "bazel-test")
+	test.Ok(t, err)
+	defer os.RemoveAll(tempDir)
+
+	expectedPath := filepath.Join(tempDir, ".bazel")
+	diskCache := filepath.Join(expectedPath, "disk")
+
+	// Entries of 10 bytes, used one hour apart, the oldest first.
+	var entries []string
+
+	for i, name := range []string{"ac/old", "cas/older", "cas/recent", "cas/newest"} {
+		entry := filepath.Join(diskCache, filepath.FromSlash(name))
+		test.Ok(t, os.MkdirAll(filepath.Dir(entry), 0755))
+		test.Ok(t, os.WriteFile(entry, []byte("0123456789"), 0644))
+
+		used := time.Now().Add(time.Duration(i-4) * time.Hour)
+		test.Ok(t, os.Chtimes(entry, used, used))
+
+		entries = append(entries, entry)
+	}
+
+	pathToCache, err := newBazelPreparer(25).PrepareRepo(tempDir)
+	test.Ok(t, err)
+	test.Equals(t, expectedPath, pathToCache)
+
+	content, err := os.ReadFile(filepath.Join(tempDir, ".bazelrc"))
+	test.Ok(t, err)
+
+	contentStr := string(content)
+	test.Assert(t, strings.Contains(contentStr, "build --repository_cache="+filepath.Join(expectedPath, "repository")+"\n"),
+		"Content should contain repository cache path")
+	test.Assert(t, strings.Contains(contentStr, "build --disk_cache="+diskCache+"\n"), "Content should contain disk cache path")
+
+	// The least recently used entries are removed, until the disk cache fits.
+	for i, entry := range entries {
+		_, err := os.Stat(entry)
+		if i < 2 {
+			test.Assert(t, os.IsNotExist(err), "%s should be removed", entry)
+		} else {
+			test.Ok(t, err)
+		}
+	}
+}
@@ -0,0 +1,57 @@
+package autodetect
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+var bundlePath = regexp.MustCompile(`(?m)^BUNDLE_PATH:[ \t]*(.*)$`)
+
+// bundlerPreparer caches gems installed by Bundler, into the path configured in .bundle/config.
+// A path configured by the project is cached as is.
+type bundlerPreparer struct{}
+
+func newBundlerPreparer() *bundlerPreparer {
+	return &bundlerPreparer{}
+}
+
+func (*bundlerPreparer) PrepareRepo(dir string) (string, error) {
+	configPath := filepath.Join(dir, ".bundle", "config")
+	pathToCache := filepath.Join(dir, "vendor", "bundle")
+
+	content, err := os.ReadFile(configPath)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return "", err
+	}
+
+	if match := bundlePath.FindSubmatch(content); match != nil {
+		configured := strings.Trim(strings.TrimSpace(string(match[1])), `"'`)
+		if !filepath.IsAbs(configured) {
+			configured = filepath.Join(dir, configured)
+		}
+
+		return configured, nil
+	}
+
+	if len(content) == 0 {
+		content = []byte("---\n")
+	} else if !strings.HasSuffix(string(content), "\n") {
+		content = append(content, '\n')
+	}
+
+	if err := os.MkdirAll(filepath.Dir(configPath), os.ModePerm); err != nil {
+		return "", err
+	}
+
+	content = append(content, fmt.Sprintf("BUNDLE_PATH: %q\n", pathToCache)...)
+
+	if err := writeConfig(configPath, content); err != nil {
+		return "", err
+	}
+
+	return pathToCache, nil
+}
@@ -0,0 +1,61 @@
+package autodetect
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/meltwater/drone-cache/test"
+)
+
+func TestBundlerPreparerPrepareRepo(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		existing string
+		cacheDir string
+		expected string
+	}{
+		{
+			name:     "without config",
+			cacheDir: "vendor/bundle",
+			expected: "---\nBUNDLE_PATH: \"{dir}/vendor/bundle\"\n",
+		},
+		{
+			name:     "with config",
+			existing: "---\nBUNDLE_WITHOUT: \"development\"",
+			cacheDir: "vendor/bundle",
+			expected: "---\nBUNDLE_WITHOUT: \"development\"\nBUNDLE_PATH: \"{dir}/vendor/bundle\"\n",
+		},
+		{
+			name:     "with custom path",
+			existing: "---\nBUNDLE_PATH: \"gems\"\n",
+			cacheDir: "gems",
+			expected: "---\nBUNDLE_PATH: \"gems\"\n",
+		},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			tempDir, err := os.MkdirTemp("", "bundler-test")
+			test.Ok(t, err)
+			defer os.RemoveAll(tempDir)
+
+			configPath := filepath.Join(tempDir, ".bundle", "config")
+			if tc.existing != "" {
+				test.Ok(t, os.MkdirAll(filepath.Dir(configPath), 0755))
+				test.Ok(t, os.WriteFile(configPath, []byte(tc.existing), 0644))
+			}
+
+			// Preparing twice must not change the configuration twice.
+			for i := 0; i < 2; i++ {
+				pathToCache, err := newBundlerPreparer().PrepareRepo(tempDir)
+				test.Ok(t, err)
+				test.Equals(t, filepath.Join(tempDir, filepath.FromSlash(tc.cacheDir)), pathToCache)
+			}
+
+			content, err := os.ReadFile(configPath)
+			test.Ok(t, err)
+			test.Equals(t, strings.ReplaceAll(tc.expected, "{dir}", tempDir), string(content))
+		})
+	}
+}
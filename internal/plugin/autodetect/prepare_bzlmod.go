@@ -1,22 +1,20 @@
 package autodetect
 
 import (
-	"errors"
 	"fmt"
-	"os"
 	"path/filepath"
 )
 
-// Variable for testing - allows mocking os.OpenFile
-var osOpenFile = os.OpenFile
-
-type bzlmodPreparer struct{}
+type bzlmodPreparer struct {
+	// diskCacheSize is the size the disk cache is trimmed to, if positive.
+	diskCacheSize int64
+}
 
-func newBzlmodPreparer() *bzlmodPreparer {
-	return &bzlmodPreparer{}
+func newBzlmodPreparer(diskCacheSize int64) *bzlmodPreparer {
+	return &bzlmodPreparer{diskCacheSize: diskCacheSize}
 }
 
-func (*bzlmodPreparer) PrepareRepo(dir string) (string, error) {
+func (p *bzlmodPreparer) PrepareRepo(dir string) (string, error) {
 	fileName := filepath.Join(dir, ".bazelrc")
 	pathToCache := filepath.Join(dir, ".bazel")
 
@@ -24,43 +22,24 @@ func (*bzlmodPreparer) PrepareRepo(dir string) (string, error) {
 	moduleCache := filepath.Join(pathToCache, "module")
 	registryCache := filepath.Join(pathToCache, "registry")
 
-	cmdToOverrideRepo := fmt.Sprintf(`
-	build --test_tmpdir=%s
-	test --test_tmpdir=%s
-	build --module_cache=%s
-	build --registry_cache=%s
-	`,
+	cmdToOverrideRepo := fmt.Sprintf(`build --test_tmpdir=%s
+test --test_tmpdir=%s
+build --module_cache=%s
+build --registry_cache=%s
+%s`,
 		runtimeCache,
 		runtimeCache,
 		moduleCache,
 		registryCache,
+		bazelCaches(pathToCache),
 	)
 
-	if _, err := os.Stat(fileName); errors.Is(err, os.ErrNotExist) {
-		f, err := os.Create(fileName)
-		if err != nil {
-			return "", err
-		}
-		defer f.Close()
-		_, err = f.WriteString(cmdToOverrideRepo)
-
-		if err != nil {
-			return "", err
-		}
-
-		return pathToCache, nil
-	}
-
-	f, err := osOpenFile(fileName, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644) //nolint:gomnd
-
-	if err != nil {
+	if err := setManagedBlock(fileName, "#", "bzlmod", cmdToOverrideRepo); err != nil {
 		return "", err
 	}
-	defer f.Close()
-	_, err = f.WriteString(cmdToOverrideRepo)
 
-	if err != nil {
-		return "", err
+	if err := trimBazelDiskCache(filepath.Join(pathToCache, "disk"), p.diskCacheSize); err != nil {
+		return "", fmt.Errorf("trim bazel disk cache, %w", err)
 	}
 
 	return pathToCache, nil
@@ -0,0 +1,114 @@
+package autodetect
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+var nextTable = regexp.MustCompile(`(?m)^[ \t]*\[`)
+
+const cargoCacheDir = ".cargo-cache"
+
+// cargoPreparer caches the registry and git checkouts of Cargo builds, and optionally their target directory.
+// Cargo does not read its home from configuration files, CARGO_HOME has to point to the cached directory.
+type cargoPreparer struct {
+	cacheTarget bool
+}
+
+func newCargoPreparer(cacheTarget bool) *cargoPreparer {
+	return &cargoPreparer{cacheTarget: cacheTarget}
+}
+
+func (p *cargoPreparer) PrepareRepo(dir string) (string, error) {
+	pathToCache := filepath.Join(dir, cargoCacheDir)
+
+	if !p.cacheTarget {
+		return pathToCache, nil
+	}
+
+	if err := os.MkdirAll(filepath.Join(dir, ".cargo"), os.ModePerm); err != nil {
+		return "", err
+	}
+
+	if err := setInTable(filepath.Join(dir, ".cargo", "config.toml"), "build",
+		fmt.Sprintf("target-dir = %q", filepath.Join(pathToCache, "target"))); err != nil {
+		return "", err
+	}
+
+	return pathToCache, nil
+}
+
+// IsCargoHome returns true if given cached directory is the Cargo home of a project.
+// Cargo does not read its home from configuration files, CARGO_HOME has to point to it in build steps.
+func IsCargoHome(pathToCache string) bool {
+	return filepath.Base(pathToCache) == cargoCacheDir
+}
+
+// CargoExcludes returns patterns of paths below given cached directory that are left out of the cache,
+// if it is the Cargo home of a project.
+// NOTICE: Incremental compilation artifacts of every profile and target are not reusable between builds
+// on other runners, and they are the biggest part of target directories.
+func CargoExcludes(pathToCache string) []string {
+	if !IsCargoHome(pathToCache) {
+		return nil
+	}
+
+	return []string{"target/*/incremental", "target/*/*/incremental"}
+}
+
+// setInTable sets given "key = value" settings in given table of a TOML or INI file, unless their keys are already set.
+func setInTable(fileName, table string, settings ...string) error {
+	content, err := os.ReadFile(fileName)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+
+	header := regexp.MustCompile(`(?m)^\[` + regexp.QuoteMeta(table) + `\][ \t]*$`)
+	loc := header.FindIndex(content)
+
+	var section []byte
+
+	if loc != nil {
+		section = content[loc[1]:]
+		if next := nextTable.FindIndex(section); next != nil {
+			section = section[:next[0]]
+		}
+	}
+
+	var missing []string
+
+	for _, setting := range settings {
+		key := strings.TrimSpace(strings.SplitN(setting, "=", 2)[0]) //nolint:gomnd
+		if !regexp.MustCompile(`(?m)^\s*` + regexp.QuoteMeta(key) + `\s*=`).Match(section) {
+			missing = append(missing, setting)
+		}
+	}
+
+	if len(missing) == 0 {
+		return nil
+	}
+
+	lines := strings.Join(missing, "\n")
+
+	if loc != nil {
+		updated := string(content[:loc[1]]) + "\n" + lines + string(content[loc[1]:])
+
+		return writeConfig(fileName, []byte(updated))
+	}
+
+	if len(content) > 0 && !strings.HasSuffix(string(content), "\n") {
+		content = append(content, '\n')
+	}
+
+	if len(content) > 0 {
+		content = append(content, '\n')
+	}
+
+	updated := fmt.Sprintf("%s[%s]\n%s\n", content, table, lines)
+
+	return writeConfig(fileName, []byte(updated))
+}
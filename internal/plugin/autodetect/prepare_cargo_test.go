@@ -0,0 +1,92 @@
+package autodetect
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/meltwater/drone-cache/test"
+)
+
+func TestCargoPreparerPrepareRepo(t *testing.T) {
+	for _, tc := range []struct {
+		name        string
+		cacheTarget bool
+		existing    string
+		expected    string
+	}{
+		{
+			name:        "with target",
+			cacheTarget: true,
+			expected:    "[build]\ntarget-dir = \"{cache}/target\"\n",
+		},
+		{
+			name:        "with other tables",
+			cacheTarget: true,
+			existing:    "[profile.dev]\nincremental = true",
+			expected:    "[profile.dev]\nincremental = true\n\n[build]\ntarget-dir = \"{cache}/target\"\n",
+		},
+		{
+			name:        "with build table",
+			cacheTarget: true,
+			existing:    "[build]\njobs = 4\n\n[net]\nretry = 2\n",
+			expected:    "[build]\ntarget-dir = \"{cache}/target\"\njobs = 4\n\n[net]\nretry = 2\n",
+		},
+		{
+			name:        "with settings",
+			cacheTarget: true,
+			existing:    "[build]\ntarget-dir = \"out\"\n",
+			expected:    "[build]\ntarget-dir = \"out\"\n",
+		},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			tempDir, err := os.MkdirTemp("", "cargo-test")
+			test.Ok(t, err)
+			defer os.RemoveAll(tempDir)
+
+			configPath := filepath.Join(tempDir, ".cargo", "config.toml")
+			if tc.existing != "" {
+				test.Ok(t, os.MkdirAll(filepath.Dir(configPath), 0755))
+				test.Ok(t, os.WriteFile(configPath, []byte(tc.existing), 0644))
+			}
+
+			expectedPath := filepath.Join(tempDir, ".cargo-cache")
+
+			// Preparing twice must not change the configuration twice.
+			for i := 0; i < 2; i++ {
+				pathToCache, err := newCargoPreparer(tc.cacheTarget).PrepareRepo(tempDir)
+				test.Ok(t, err)
+				test.Equals(t, expectedPath, pathToCache)
+			}
+
+			content, err := os.ReadFile(configPath)
+			test.Ok(t, err)
+			test.Equals(t, strings.ReplaceAll(tc.expected, "{cache}", expectedPath), string(content))
+		})
+	}
+}
+
+func TestCargoPreparerPrepareRepoWithoutTarget(t *testing.T) {
+	tempDir, err := os.MkdirTemp("", "cargo-test")
+	test.Ok(t, err)
+	defer os.RemoveAll(tempDir)
+
+	pathToCache, err := newCargoPreparer(false).PrepareRepo(tempDir)
+	test.Ok(t, err)
+	test.Equals(t, filepath.Join(tempDir, ".cargo-cache"), pathToCache)
+
+	_, err = os.Stat(filepath.Join(tempDir, ".cargo"))
+	test.Assert(t, os.IsNotExist(err), ".cargo should not be created")
+}
+
+func TestCargoExcludes(t *testing.T) {
+	dir := filepath.Join("workspace", "app")
+
+	test.Assert(t, IsCargoHome(filepath.Join(dir, ".cargo-cache")), "cargo cache should be a cargo home")
+	test.Equals(t, []string{"target/*/incremental", "target/*/*/incremental"}, CargoExcludes(filepath.Join(dir, ".cargo-cache")))
+
+	test.Assert(t, !IsCargoHome(filepath.Join(dir, ".cache", "pip")), "pip cache should not be a cargo home")
+	test.Equals(t, 0, len(CargoExcludes(filepath.Join(dir, ".cache", "pip"))))
+}
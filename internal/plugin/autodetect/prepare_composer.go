@@ -0,0 +1,50 @@
+package autodetect
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// composerPreparer caches the vendor directory of Composer projects.
+// NOTICE: composer.json is owned by the project, the cache directory of Composer is not configured in it.
+type composerPreparer struct{}
+
+func newComposerPreparer() *composerPreparer {
+	return &composerPreparer{}
+}
+
+func (*composerPreparer) PrepareRepo(dir string) (string, error) {
+	pathToCache := filepath.Join(dir, "vendor")
+
+	data, err := os.ReadFile(filepath.Join(dir, "composer.json"))
+	if errors.Is(err, os.ErrNotExist) {
+		return pathToCache, nil
+	}
+
+	if err != nil {
+		return "", err
+	}
+
+	var manifest struct {
+		Config struct {
+			VendorDir string `json:"vendor-dir"`
+		} `json:"config"`
+	}
+
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return "", fmt.Errorf("failed to parse composer.json: %w", err)
+	}
+
+	if vendorDir := manifest.Config.VendorDir; vendorDir != "" {
+		if filepath.IsAbs(vendorDir) {
+			return vendorDir, nil
+		}
+
+		return filepath.Join(dir, vendorDir), nil
+	}
+
+	return pathToCache, nil
+}
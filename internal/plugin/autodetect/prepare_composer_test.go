@@ -0,0 +1,55 @@
+package autodetect
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/meltwater/drone-cache/test"
+)
+
+func TestComposerPreparerPrepareRepo(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		manifest string
+		cacheDir string
+	}{
+		{name: "without manifest", cacheDir: "vendor"},
+		{name: "default vendor directory", manifest: `{"require": {"php": ">=8.1"}}`, cacheDir: "vendor"},
+		{name: "custom vendor directory", manifest: `{"config": {"vendor-dir": "lib/vendor"}}`, cacheDir: "lib/vendor"},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			tempDir, err := os.MkdirTemp("", "composer-test")
+			test.Ok(t, err)
+			defer os.RemoveAll(tempDir)
+
+			manifestPath := filepath.Join(tempDir, "composer.json")
+			if tc.manifest != "" {
+				test.Ok(t, os.WriteFile(manifestPath, []byte(tc.manifest), 0644))
+			}
+
+			pathToCache, err := newComposerPreparer().PrepareRepo(tempDir)
+			test.Ok(t, err)
+			test.Equals(t, filepath.Join(tempDir, filepath.FromSlash(tc.cacheDir)), pathToCache)
+
+			// The manifest is owned by the project and must not be changed.
+			if tc.manifest != "" {
+				content, err := os.ReadFile(manifestPath)
+				test.Ok(t, err)
+				test.Equals(t, tc.manifest, string(content))
+			}
+		})
+	}
+}
+
+func TestComposerPreparerInvalidManifest(t *testing.T) {
+	tempDir, err := os.MkdirTemp("", "composer-test")
+	test.Ok(t, err)
+	defer os.RemoveAll(tempDir)
+
+	test.Ok(t, os.WriteFile(filepath.Join(tempDir, "composer.json"), []byte("{"), 0644))
+
+	_, err = newComposerPreparer().PrepareRepo(tempDir)
+	test.NotOk(t, err)
+}
@@ -37,19 +37,13 @@ func (*dotnetPreparer) PrepareRepo(dir string) (string, error) {
 
 	//file not exists
 	if _, err := os.Stat(configPath); errors.Is(err, os.ErrNotExist) {
-		f, err := os.Create(configPath)
-		if err != nil {
-			return "", err
-		}
-		defer f.Close()
-
 		configuration := Configuration{}
 		marshalledConfig, err := xml.MarshalIndent(configuration, "", "  ")
 		if err != nil {
 			return "", err
 		}
 
-		err = os.WriteFile(configPath, marshalledConfig, 0644)
+		err = writeConfig(configPath, marshalledConfig)
 		if err != nil {
 			return "", fmt.Errorf("failed to write nuget.config: %w", err)
 		}
@@ -99,7 +93,9 @@ func (*dotnetPreparer) PrepareRepo(dir string) (string, error) {
 	}
 
 	if updateConfig {
-		writeConfigurationToFile(configPath, configuration)
+		if err := writeConfigurationToFile(configPath, configuration); err != nil {
+			return "", err
+		}
 	}
 
 	return pathToCache, nil
@@ -110,7 +106,7 @@ func writeConfigurationToFile(filePath string, config Configuration) error {
 	if err != nil {
 		return fmt.Errorf("failed to marshal updated nuget.config: %w", err)
 	}
-	err = os.WriteFile(filePath, updatedData, 0644)
+	err = writeConfig(filePath, updatedData)
 	if err != nil {
 		return fmt.Errorf("failed to write updated nuget.config: %w", err)
 	}
@@ -1,11 +1,85 @@
 package autodetect
 
-type goPreparer struct{}
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+)
 
-func newGoPreparer() *goPreparer {
-	return &goPreparer{}
+// goPreparer caches the module and build caches of Go modules, relocated into the workspace by an env file.
+// Go reads the env file when GOENV points to it, it can be sourced by shells as well.
+type goPreparer struct {
+	// trim removes build cache entries that were not used for longer than it, if positive.
+	trim time.Duration
 }
 
-func (*goPreparer) PrepareRepo(dir string) (string, error) {
-	return ".go", nil
+const goCacheDir = ".go"
+
+func newGoPreparer(trim time.Duration) *goPreparer {
+	return &goPreparer{trim: trim}
+}
+
+func (p *goPreparer) PrepareRepo(dir string) (string, error) {
+	pathToCache := filepath.Join(dir, goCacheDir)
+	buildCache := filepath.Join(pathToCache, "cache")
+
+	if err := os.MkdirAll(pathToCache, os.ModePerm); err != nil {
+		return "", err
+	}
+
+	env := fmt.Sprintf("GOMODCACHE=%s\nGOCACHE=%s\n", filepath.Join(pathToCache, "pkg", "mod"), buildCache)
+	if err := setManagedBlock(filepath.Join(pathToCache, "env"), "#", "go", env); err != nil {
+		return "", err
+	}
+
+	if p.trim > 0 {
+		if err := trimGoBuildCache(buildCache, time.Now().Add(-p.trim)); err != nil {
+			return "", fmt.Errorf("trim go build cache, %w", err)
+		}
+	}
+
+	return pathToCache, nil
+}
+
+// GoEnvFile returns the env file configuring given cached directory, if it is the Go cache of a project.
+// Go does not read env files of projects, GOENV has to point to it in build steps.
+func GoEnvFile(pathToCache string) (string, bool) {
+	if filepath.Base(pathToCache) != goCacheDir {
+		return "", false
+	}
+
+	return filepath.Join(pathToCache, "env"), true
+}
+
+// trimGoBuildCache removes entries of given build cache that were last used before given time.
+// NOTICE: Go updates modification times of build cache entries when they are used, at most once an hour.
+func trimGoBuildCache(buildCache string, before time.Time) error {
+	err := filepath.WalkDir(buildCache, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		// Entries are stored in subdirectories named after the first byte of their hash, other files are kept.
+		if d.IsDir() || len(filepath.Base(filepath.Dir(path))) != 2 || filepath.Dir(filepath.Dir(path)) != buildCache {
+			return nil
+		}
+
+		fi, err := d.Info()
+		if err != nil {
+			return err
+		}
+
+		if fi.ModTime().Before(before) {
+			return os.Remove(path)
+		}
+
+		return nil
+	})
+	if os.IsNotExist(err) {
+		return nil
+	}
+
+	return err
 }
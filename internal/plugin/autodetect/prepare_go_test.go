@@ -0,0 +1,66 @@
+package autodetect
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/meltwater/drone-cache/test"
+)
+
+func TestGoPreparerPrepareRepo(t *testing.T) {
+	tempDir, err := os.MkdirTemp("", "go-test")
+	test.Ok(t, err)
+	defer os.RemoveAll(tempDir)
+
+	expectedPath := filepath.Join(tempDir, ".go")
+	buildCache := filepath.Join(expectedPath, "cache")
+
+	stale := filepath.Join(buildCache, "ab", "stale-a")
+	fresh := filepath.Join(buildCache, "ab", "fresh-a")
+	trim := filepath.Join(buildCache, "trim.txt")
+
+	test.Ok(t, os.MkdirAll(filepath.Dir(stale), 0755))
+
+	for _, f := range []string{stale, fresh, trim} {
+		test.Ok(t, os.WriteFile(f, []byte(testFileContent), 0644))
+	}
+
+	old := time.Now().Add(-48 * time.Hour)
+	test.Ok(t, os.Chtimes(stale, old, old))
+	test.Ok(t, os.Chtimes(trim, old, old))
+
+	// Preparing twice must not change the env file twice.
+	for i := 0; i < 2; i++ {
+		pathToCache, err := newGoPreparer(24 * time.Hour).PrepareRepo(tempDir)
+		test.Ok(t, err)
+		test.Equals(t, expectedPath, pathToCache)
+	}
+
+	content, err := os.ReadFile(filepath.Join(expectedPath, "env"))
+	test.Ok(t, err)
+	test.Equals(t, "# BEGIN drone-cache autodetect go\n"+
+		"GOMODCACHE="+filepath.Join(expectedPath, "pkg", "mod")+"\n"+
+		"GOCACHE="+buildCache+"\n"+
+		"# END drone-cache autodetect go\n", string(content))
+
+	_, err = os.Stat(stale)
+	test.Assert(t, os.IsNotExist(err), "stale build cache entries should be removed")
+
+	for _, f := range []string{fresh, trim} {
+		_, err = os.Stat(f)
+		test.Ok(t, err)
+	}
+}
+
+func TestGoEnvFile(t *testing.T) {
+	dir := filepath.Join("workspace", "app")
+
+	env, ok := GoEnvFile(filepath.Join(dir, ".go"))
+	test.Assert(t, ok, "go cache should have an env file")
+	test.Equals(t, filepath.Join(dir, ".go", "env"), env)
+
+	_, ok = GoEnvFile(filepath.Join(dir, ".cargo-cache"))
+	test.Assert(t, !ok, "cargo cache should not have a go env file")
+}
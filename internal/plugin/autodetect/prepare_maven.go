@@ -5,8 +5,12 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"regexp"
+	"strings"
 )
 
+var mavenRepoLocal = regexp.MustCompile(`-Dmaven\.repo\.local=\S*`)
+
 type mavenPreparer struct{}
 
 func newMavenPreparer() *mavenPreparer {
@@ -14,41 +18,36 @@ func newMavenPreparer() *mavenPreparer {
 }
 func (*mavenPreparer) PrepareRepo(dir string) (string, error) {
 	configPath := filepath.Join(dir, ".mvn")
-	fileName := "maven.config"
+	fileName := filepath.Join(configPath, "maven.config")
 	pathToCache := filepath.Join(dir, ".m2", "repository")
-	cmdToOverrideRepo := fmt.Sprintf("-Dmaven.repo.local=%s ", pathToCache)
-
-	if _, err := os.Stat(filepath.Join(configPath, fileName)); errors.Is(err, os.ErrNotExist) {
-		err := os.MkdirAll(configPath, os.ModePerm)
+	cmdToOverrideRepo := fmt.Sprintf("-Dmaven.repo.local=%s", pathToCache)
 
-		if err != nil {
-			return "", err
-		}
+	if err := os.MkdirAll(configPath, os.ModePerm); err != nil {
+		return "", err
+	}
 
-		f, err := os.Create(filepath.Join(configPath, fileName))
+	content, err := os.ReadFile(fileName)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return "", err
+	}
 
-		if err != nil {
-			return "", err
-		}
-		defer f.Close()
-		_, err = f.WriteString(cmdToOverrideRepo)
+	// NOTICE: maven.config does not support comments, the override is replaced in place instead of in a managed block.
+	existing := string(content)
+	updated := mavenRepoLocal.ReplaceAllLiteralString(existing, cmdToOverrideRepo)
 
-		if err != nil {
-			return "", err
+	if !mavenRepoLocal.MatchString(existing) {
+		if existing != "" && !strings.HasSuffix(existing, "\n") {
+			updated += "\n"
 		}
 
-		return pathToCache, err
+		updated += cmdToOverrideRepo + "\n"
 	}
 
-	f, err := os.OpenFile(filepath.Join(configPath, fileName), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644) //nolint:gomnd
-
-	if err != nil {
-		return "", err
+	if updated == existing {
+		return pathToCache, nil
 	}
-	defer f.Close()
-	_, err = f.WriteString(cmdToOverrideRepo)
 
-	if err != nil {
+	if err := writeConfig(fileName, []byte(updated)); err != nil {
 		return "", err
 	}
 
@@ -1,6 +1,21 @@
 package autodetect
 
-import "path/filepath"
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// Caches of Node projects.
+const (
+	// NodeModules caches the node_modules directory of the project.
+	NodeModules = "node_modules"
+	// NodeStore caches the package store of the package manager of the project.
+	NodeStore = "store"
+)
 
 type nodePreparer struct{}
 
@@ -11,3 +26,77 @@ func newNodePreparer() *nodePreparer {
 func (*nodePreparer) PrepareRepo(dir string) (string, error) {
 	return filepath.Join(dir, "node_modules"), nil
 }
+
+// npmPreparer caches either node_modules or the npm cache, configured with the cache setting of .npmrc.
+type npmPreparer struct {
+	store bool
+}
+
+func newNpmPreparer(store bool) *npmPreparer {
+	return &npmPreparer{store: store}
+}
+
+func (p *npmPreparer) PrepareRepo(dir string) (string, error) {
+	if !p.store {
+		return newNodePreparer().PrepareRepo(dir)
+	}
+
+	return setNpmrc(dir, "npm", "cache", filepath.Join(dir, ".npm"))
+}
+
+// pnpmPreparer caches either node_modules or the pnpm content store, configured with the store-dir setting of .npmrc.
+type pnpmPreparer struct {
+	store bool
+}
+
+func newPnpmPreparer(store bool) *pnpmPreparer {
+	return &pnpmPreparer{store: store}
+}
+
+func (p *pnpmPreparer) PrepareRepo(dir string) (string, error) {
+	if !p.store {
+		return newNodePreparer().PrepareRepo(dir)
+	}
+
+	return setNpmrc(dir, "pnpm", "store-dir", filepath.Join(dir, ".pnpm-store"))
+}
+
+// setNpmrc sets given key of the .npmrc file of the project to given path in a managed block, and returns the path.
+// A path configured by the project is returned instead, relative paths are relative to the project.
+func setNpmrc(dir, name, key, pathToCache string) (string, error) {
+	fileName := filepath.Join(dir, ".npmrc")
+
+	content, err := os.ReadFile(fileName)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return "", err
+	}
+
+	setting := regexp.MustCompile(`(?m)^[ \t]*` + regexp.QuoteMeta(key) + `[ \t]*=[ \t]*(.*?)[ \t\r]*$`)
+	if match := setting.FindStringSubmatch(withoutManagedBlock(string(content), "#", name)); match != nil {
+		return projectPath(dir, strings.Trim(match[1], `"'`))
+	}
+
+	if err := setManagedBlock(fileName, "#", name, fmt.Sprintf("%s=%s", key, pathToCache)); err != nil {
+		return "", err
+	}
+
+	return pathToCache, nil
+}
+
+// projectPath resolves a path configured by the project in given directory. Paths starting with ~ are relative to
+// the home directory, like npm resolves them, other relative paths are relative to the project.
+func projectPath(dir, configured string) (string, error) {
+	switch {
+	case configured == "~" || strings.HasPrefix(configured, "~/"):
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", fmt.Errorf("resolve <%s>, %w", configured, err)
+		}
+
+		return filepath.Join(home, strings.TrimPrefix(configured, "~")), nil
+	case filepath.IsAbs(configured):
+		return configured, nil
+	default:
+		return filepath.Join(dir, configured), nil
+	}
+}
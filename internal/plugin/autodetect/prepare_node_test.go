@@ -0,0 +1,100 @@
+package autodetect
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/meltwater/drone-cache/test"
+)
+
+func TestNodePreparersPrepareRepo(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		preparer RepoPreparer
+		existing string
+		cacheDir string
+		expected string
+	}{
+		{name: "npm node_modules", preparer: newNpmPreparer(false), cacheDir: "node_modules"},
+		{name: "pnpm node_modules", preparer: newPnpmPreparer(false), cacheDir: "node_modules"},
+		{
+			name:     "npm store",
+			preparer: newNpmPreparer(true),
+			existing: "registry=https://registry.npmjs.org/",
+			cacheDir: ".npm",
+			expected: "registry=https://registry.npmjs.org/\n# BEGIN drone-cache autodetect npm\ncache={dir}/.npm\n# END drone-cache autodetect npm\n",
+		},
+		{
+			name:     "pnpm store",
+			preparer: newPnpmPreparer(true),
+			cacheDir: ".pnpm-store",
+			expected: "# BEGIN drone-cache autodetect pnpm\nstore-dir={dir}/.pnpm-store\n# END drone-cache autodetect pnpm\n",
+		},
+		{
+			name:     "pnpm custom store",
+			preparer: newPnpmPreparer(true),
+			existing: "store-dir = store\n",
+			cacheDir: "store",
+			expected: "store-dir = store\n",
+		},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			tempDir, err := os.MkdirTemp("", "node-test")
+			test.Ok(t, err)
+			defer os.RemoveAll(tempDir)
+
+			npmrcPath := filepath.Join(tempDir, ".npmrc")
+			if tc.existing != "" {
+				test.Ok(t, os.WriteFile(npmrcPath, []byte(tc.existing), 0644))
+			}
+
+			// Preparing twice must not change the configuration twice.
+			for i := 0; i < 2; i++ {
+				pathToCache, err := tc.preparer.PrepareRepo(tempDir)
+				test.Ok(t, err)
+				test.Equals(t, filepath.Join(tempDir, tc.cacheDir), pathToCache)
+			}
+
+			content, err := os.ReadFile(npmrcPath)
+			if tc.expected == "" {
+				test.Assert(t, os.IsNotExist(err), ".npmrc should not be created")
+				return
+			}
+
+			test.Ok(t, err)
+			test.Equals(t, strings.ReplaceAll(tc.expected, "{dir}", tempDir), string(content))
+		})
+	}
+}
+
+func TestSetNpmrcConfiguredByProject(t *testing.T) {
+	home, err := os.UserHomeDir()
+	test.Ok(t, err)
+
+	for _, tc := range []struct {
+		name     string
+		existing string
+		expected string
+	}{
+		{name: "home", existing: "cache=~/.npm-cache\n", expected: filepath.Join(home, ".npm-cache")},
+		{name: "absolute", existing: "cache=/var/cache/npm\n", expected: "/var/cache/npm"},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			tempDir := t.TempDir()
+			npmrcPath := filepath.Join(tempDir, ".npmrc")
+			test.Ok(t, os.WriteFile(npmrcPath, []byte(tc.existing), 0644))
+
+			pathToCache, err := newNpmPreparer(true).PrepareRepo(tempDir)
+			test.Ok(t, err)
+			test.Equals(t, tc.expected, pathToCache)
+
+			content, err := os.ReadFile(npmrcPath)
+			test.Ok(t, err)
+			test.Equals(t, tc.existing, string(content))
+		})
+	}
+}
@@ -0,0 +1,165 @@
+package autodetect
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+var (
+	poetryTable = regexp.MustCompile(`(?m)^\[tool\.poetry\]`)
+	uvTable     = regexp.MustCompile(`(?m)^\[tool\.uv\][ \t]*$`)
+	cacheDirKey = regexp.MustCompile(`(?m)^\s*cache-dir\s*=`)
+)
+
+// pipCacheDir is the pip cache of projects, relative to their directory.
+var pipCacheDir = filepath.Join(".cache", "pip")
+
+// pipPreparer caches the pip cache of projects with requirements files.
+// pip has no project configuration, the written pip.conf is read when PIP_CONFIG_FILE points to it.
+type pipPreparer struct{}
+
+func newPipPreparer() *pipPreparer {
+	return &pipPreparer{}
+}
+
+func (*pipPreparer) PrepareRepo(dir string) (string, error) {
+	pathToCache := filepath.Join(dir, pipCacheDir)
+
+	// NOTICE: pip.conf is an INI file, the setting is added to an existing global section.
+	if err := setInTable(filepath.Join(dir, "pip.conf"), "global", "cache-dir = "+pathToCache); err != nil {
+		return "", err
+	}
+
+	return pathToCache, nil
+}
+
+// PipConfigFile returns the pip.conf configuring given cached directory, if it is the pip cache of a project.
+// pip does not read configuration files of projects, PIP_CONFIG_FILE has to point to it in build steps.
+func PipConfigFile(pathToCache string) (string, bool) {
+	dir := filepath.Dir(filepath.Dir(pathToCache))
+	if filepath.Join(dir, pipCacheDir) != pathToCache {
+		return "", false
+	}
+
+	return filepath.Join(dir, "pip.conf"), true
+}
+
+// poetryPreparer caches the Poetry cache, configured in the project's poetry.toml.
+type poetryPreparer struct{}
+
+func newPoetryPreparer() *poetryPreparer {
+	return &poetryPreparer{}
+}
+
+func (*poetryPreparer) PrepareRepo(dir string) (string, error) {
+	pathToCache := filepath.Join(dir, ".cache", "pypoetry")
+
+	// NOTICE: Top level keys of TOML files have to precede the first table.
+	if err := prependIfMissing(filepath.Join(dir, "poetry.toml"), cacheDirKey,
+		fmt.Sprintf("cache-dir = %q\n", pathToCache)); err != nil {
+		return "", err
+	}
+
+	return pathToCache, nil
+}
+
+// pipenvPreparer caches the virtualenv of Pipenv projects, Pipenv creates it in an existing .venv directory.
+type pipenvPreparer struct{}
+
+func newPipenvPreparer() *pipenvPreparer {
+	return &pipenvPreparer{}
+}
+
+func (*pipenvPreparer) PrepareRepo(dir string) (string, error) {
+	pathToCache := filepath.Join(dir, ".venv")
+
+	if err := os.MkdirAll(pathToCache, os.ModePerm); err != nil {
+		return "", err
+	}
+
+	return pathToCache, nil
+}
+
+// uvPreparer caches the uv cache, configured in uv.toml or the [tool.uv] table of pyproject.toml.
+type uvPreparer struct{}
+
+func newUvPreparer() *uvPreparer {
+	return &uvPreparer{}
+}
+
+func (*uvPreparer) PrepareRepo(dir string) (string, error) {
+	pathToCache := filepath.Join(dir, ".cache", "uv")
+	setting := fmt.Sprintf("cache-dir = %q\n", pathToCache)
+
+	uvConfig := filepath.Join(dir, "uv.toml")
+	pyproject := filepath.Join(dir, "pyproject.toml")
+
+	// NOTICE: uv ignores the [tool.uv] table of pyproject.toml if uv.toml exists, it must not be created then.
+	if _, err := os.Stat(uvConfig); errors.Is(err, os.ErrNotExist) {
+		content, err := os.ReadFile(pyproject)
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return "", err
+		}
+
+		if uvTable.Match(content) {
+			return pathToCache, setInTable(pyproject, "tool.uv", strings.TrimSuffix(setting, "\n"))
+		}
+	}
+
+	if err := prependIfMissing(uvConfig, cacheDirKey, setting); err != nil {
+		return "", err
+	}
+
+	return pathToCache, nil
+}
+
+// pyprojectPreparer prepares projects that only have a pyproject.toml, with the tool configured in it.
+// Projects with a lock file are not detected by their pyproject.toml, but by their lock file.
+type pyprojectPreparer struct{}
+
+func newPyprojectPreparer() *pyprojectPreparer {
+	return &pyprojectPreparer{}
+}
+
+func (*pyprojectPreparer) PrepareRepo(dir string) (string, error) {
+	content, err := os.ReadFile(filepath.Join(dir, "pyproject.toml"))
+	if err != nil {
+		return "", err
+	}
+
+	switch {
+	case poetryTable.Match(content):
+		return newPoetryPreparer().PrepareRepo(dir)
+	case uvTable.Match(content):
+		return newUvPreparer().PrepareRepo(dir)
+	default:
+		return newPipPreparer().PrepareRepo(dir)
+	}
+}
+
+// prependIfMissing writes given content at the beginning of given file, unless the file already matches the setting.
+func prependIfMissing(fileName string, setting *regexp.Regexp, content string) error {
+	existing, err := os.ReadFile(fileName)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+
+	if setting.Match(existing) {
+		return nil
+	}
+
+	if len(existing) > 0 {
+		content += "\n"
+	}
+
+	return writeConfig(fileName, append([]byte(content), existing...))
+}
+
+func fileExists(fileName string) bool {
+	_, err := os.Stat(fileName)
+	return err == nil
+}
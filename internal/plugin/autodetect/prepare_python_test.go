@@ -0,0 +1,145 @@
+package autodetect
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/meltwater/drone-cache/test"
+)
+
+func TestPythonPreparersPrepareRepo(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		preparer RepoPreparer
+		config   string
+		existing string
+		cacheDir string
+	}{
+		{name: "pip", preparer: newPipPreparer(), config: "pip.conf", cacheDir: ".cache/pip"},
+		{
+			name:     "pip with existing config",
+			preparer: newPipPreparer(),
+			config:   "pip.conf",
+			existing: "[global]\ntimeout = 60\n\n[install]\nno-compile = true\n",
+			cacheDir: ".cache/pip",
+		},
+		{name: "poetry", preparer: newPoetryPreparer(), config: "poetry.toml", cacheDir: ".cache/pypoetry"},
+		{
+			name:     "poetry with existing config",
+			preparer: newPoetryPreparer(),
+			config:   "poetry.toml",
+			existing: "[virtualenvs]\nin-project = true\n",
+			cacheDir: ".cache/pypoetry",
+		},
+		{name: "uv", preparer: newUvPreparer(), config: "uv.toml", cacheDir: ".cache/uv"},
+		{
+			name:     "uv with tool table",
+			preparer: newUvPreparer(),
+			config:   "pyproject.toml",
+			existing: "[project]\nname = \"app\"\n\n[tool.uv]\ndev-dependencies = []\n",
+			cacheDir: ".cache/uv",
+		},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			tempDir, err := os.MkdirTemp("", "python-test")
+			test.Ok(t, err)
+			defer os.RemoveAll(tempDir)
+
+			configPath := filepath.Join(tempDir, tc.config)
+			if tc.existing != "" {
+				test.Ok(t, os.WriteFile(configPath, []byte(tc.existing), 0644))
+			}
+
+			expectedPath := filepath.Join(tempDir, filepath.FromSlash(tc.cacheDir))
+
+			// Preparing twice must not configure the cache twice.
+			for i := 0; i < 2; i++ {
+				pathToCache, err := tc.preparer.PrepareRepo(tempDir)
+				test.Ok(t, err)
+				test.Equals(t, expectedPath, pathToCache)
+			}
+
+			content, err := os.ReadFile(configPath)
+			test.Ok(t, err)
+
+			contentStr := string(content)
+			test.Equals(t, 1, strings.Count(contentStr, "cache-dir"))
+			test.Assert(t, strings.Contains(contentStr, expectedPath), "Content should contain cache path")
+
+			switch tc.config {
+			case "pip.conf":
+				test.Equals(t, 1, strings.Count(contentStr, "[global]"))
+				test.Assert(t, strings.HasPrefix(contentStr, "[global]\ncache-dir"), "Cache path should be in the global section")
+				test.Assert(t, strings.Contains(contentStr, strings.TrimPrefix(tc.existing, "[global]\n")), "Content should contain initial content")
+			case "pyproject.toml":
+				test.Assert(t, strings.Contains(contentStr, "[tool.uv]\ncache-dir"), "Cache path should be in the uv table")
+				test.Assert(t, strings.Contains(contentStr, "dev-dependencies"), "Content should contain initial content")
+
+				_, err = os.Stat(filepath.Join(tempDir, "uv.toml"))
+				test.Assert(t, os.IsNotExist(err), "uv.toml should not be created")
+			default:
+				test.Assert(t, strings.HasPrefix(contentStr, "cache-dir"), "Cache path should precede tables")
+				test.Assert(t, strings.Contains(contentStr, tc.existing), "Content should contain initial content")
+			}
+		})
+	}
+}
+
+func TestPipenvPreparerPrepareRepo(t *testing.T) {
+	tempDir, err := os.MkdirTemp("", "pipenv-test")
+	test.Ok(t, err)
+	defer os.RemoveAll(tempDir)
+
+	pathToCache, err := newPipenvPreparer().PrepareRepo(tempDir)
+	test.Ok(t, err)
+	test.Equals(t, filepath.Join(tempDir, ".venv"), pathToCache)
+
+	fi, err := os.Stat(pathToCache)
+	test.Ok(t, err)
+	test.Assert(t, fi.IsDir(), ".venv should be a directory")
+}
+
+func TestPipConfigFile(t *testing.T) {
+	dir := filepath.Join("workspace", "app")
+
+	config, ok := PipConfigFile(filepath.Join(dir, ".cache", "pip"))
+	test.Assert(t, ok, "pip cache should be configured")
+	test.Equals(t, filepath.Join(dir, "pip.conf"), config)
+
+	_, ok = PipConfigFile(filepath.Join(dir, ".cache", "uv"))
+	test.Assert(t, !ok, "uv cache should not be configured in pip.conf")
+}
+
+func TestPyprojectPreparerPrepareRepo(t *testing.T) {
+	for _, tc := range []struct {
+		name      string
+		pyproject string
+		cacheDir  string
+		config    string
+	}{
+		{name: "pip", pyproject: "[project]\nname = \"app\"\n", cacheDir: ".cache/pip", config: "pip.conf"},
+		{name: "poetry", pyproject: "[tool.poetry]\nname = \"app\"\n", cacheDir: ".cache/pypoetry", config: "poetry.toml"},
+		{name: "uv", pyproject: "[tool.uv]\ndev-dependencies = []\n", cacheDir: ".cache/uv", config: "pyproject.toml"},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			tempDir, err := os.MkdirTemp("", "pyproject-test")
+			test.Ok(t, err)
+			defer os.RemoveAll(tempDir)
+
+			test.Ok(t, os.WriteFile(filepath.Join(tempDir, "pyproject.toml"), []byte(tc.pyproject), 0644))
+
+			pathToCache, err := newPyprojectPreparer().PrepareRepo(tempDir)
+			test.Ok(t, err)
+			test.Equals(t, filepath.Join(tempDir, filepath.FromSlash(tc.cacheDir)), pathToCache)
+
+			if tc.config != "" {
+				_, err = os.Stat(filepath.Join(tempDir, tc.config))
+				test.Ok(t, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,53 @@
+package autodetect
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// sbtPreparer caches the global base, Ivy and Coursier caches of sbt builds, relocated with options of .sbtopts.
+type sbtPreparer struct{}
+
+func newSbtPreparer() *sbtPreparer {
+	return &sbtPreparer{}
+}
+
+func (*sbtPreparer) PrepareRepo(dir string) (string, error) {
+	fileName := filepath.Join(dir, ".sbtopts")
+	pathToCache := filepath.Join(dir, ".sbt-cache")
+
+	content, err := os.ReadFile(fileName)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return "", err
+	}
+
+	project := withoutManagedBlock(string(content), "#", "sbt")
+
+	var missing []string
+
+	for _, property := range []struct{ key, dir string }{
+		{"sbt.global.base", "sbt"},
+		{"sbt.boot.directory", filepath.Join("sbt", "boot")},
+		{"sbt.ivy.home", "ivy2"},
+		{"sbt.coursier.home", "coursier"},
+	} {
+		// NOTICE: Properties configured by the project are kept, the directories are not cached then.
+		if !strings.Contains(project, "-D"+property.key+"=") {
+			missing = append(missing, fmt.Sprintf("-D%s=%s", property.key, filepath.Join(pathToCache, property.dir)))
+		}
+	}
+
+	// Nothing is cached if the project relocates every directory itself.
+	if len(missing) == 0 {
+		return "", nil
+	}
+
+	if err := setManagedBlock(fileName, "#", "sbt", strings.Join(missing, "\n")); err != nil {
+		return "", err
+	}
+
+	return pathToCache, nil
+}
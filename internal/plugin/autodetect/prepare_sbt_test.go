@@ -0,0 +1,56 @@
+package autodetect
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/meltwater/drone-cache/test"
+)
+
+func TestSbtPreparerPrepareRepo(t *testing.T) {
+	tempDir, err := os.MkdirTemp("", "sbt-test")
+	test.Ok(t, err)
+	defer os.RemoveAll(tempDir)
+
+	// Properties configured by the project are kept.
+	sbtoptsPath := filepath.Join(tempDir, ".sbtopts")
+	initialContent := "-J-Xmx2G\n-Dsbt.ivy.home=/opt/ivy2"
+	test.Ok(t, os.WriteFile(sbtoptsPath, []byte(initialContent), 0644))
+
+	expectedPath := filepath.Join(tempDir, ".sbt-cache")
+
+	// Preparing twice must not change the configuration twice.
+	for i := 0; i < 2; i++ {
+		pathToCache, err := newSbtPreparer().PrepareRepo(tempDir)
+		test.Ok(t, err)
+		test.Equals(t, expectedPath, pathToCache)
+	}
+
+	content, err := os.ReadFile(sbtoptsPath)
+	test.Ok(t, err)
+
+	contentStr := string(content)
+	test.Assert(t, strings.HasPrefix(contentStr, initialContent+"\n"), "Content should contain initial content")
+	test.Equals(t, 1, strings.Count(contentStr, "-Dsbt.ivy.home="))
+	test.Assert(t, strings.Contains(contentStr, "-Dsbt.global.base="+filepath.Join(expectedPath, "sbt")+"\n"), "Content should contain sbt base path")
+	test.Assert(t, strings.Contains(contentStr, "-Dsbt.coursier.home="+filepath.Join(expectedPath, "coursier")+"\n"), "Content should contain coursier path")
+}
+
+func TestSbtPreparerPrepareRepoConfiguredByProject(t *testing.T) {
+	tempDir := t.TempDir()
+
+	sbtoptsPath := filepath.Join(tempDir, ".sbtopts")
+	initialContent := "-Dsbt.global.base=/opt/sbt\n-Dsbt.boot.directory=/opt/sbt/boot\n" +
+		"-Dsbt.ivy.home=/opt/ivy2\n-Dsbt.coursier.home=/opt/coursier\n"
+	test.Ok(t, os.WriteFile(sbtoptsPath, []byte(initialContent), 0644))
+
+	pathToCache, err := newSbtPreparer().PrepareRepo(tempDir)
+	test.Ok(t, err)
+	test.Equals(t, "", pathToCache)
+
+	content, err := os.ReadFile(sbtoptsPath)
+	test.Ok(t, err)
+	test.Equals(t, initialContent, string(content))
+}
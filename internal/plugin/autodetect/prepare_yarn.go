@@ -1,9 +1,7 @@
 package autodetect
 
 import (
-	"errors"
 	"fmt"
-	"os"
 	"path/filepath"
 )
 
@@ -15,13 +13,13 @@ func newYarnPreparer() *yarnPreparer {
 func (*yarnPreparer) PrepareRepo(dir string) (string, error) {
 	pathToCache := filepath.Join(dir, ".yarn")
 	// for yarn 1.x
-	err := prepareYarn(pathToCache, filepath.Join(dir, ".yarnrc"), "\n--cache-folder %s\n")
+	err := prepareYarn(pathToCache, filepath.Join(dir, ".yarnrc"), "--cache-folder %s\n")
 	if err != nil {
 		return "", err
 	}
 
 	// for yarn 2.x
-	err = prepareYarn(pathToCache, filepath.Join(dir, ".yarnrc.yaml"), "\ncacheFolder: \"%s\"\n")
+	err = prepareYarn(pathToCache, filepath.Join(dir, ".yarnrc.yaml"), "cacheFolder: \"%s\"\n")
 	if err != nil {
 		return "", err
 	}
@@ -32,32 +30,5 @@ func (*yarnPreparer) PrepareRepo(dir string) (string, error) {
 func prepareYarn(pathToCache string, fileToWrite string, contentToWrite string) error {
 	cmdToOverrideRepo := fmt.Sprintf(contentToWrite, pathToCache)
 
-	if _, err := os.Stat(fileToWrite); errors.Is(err, os.ErrNotExist) {
-		f, err := os.Create(fileToWrite)
-		if err != nil {
-			return err
-		}
-		defer f.Close()
-		_, err = f.WriteString(cmdToOverrideRepo)
-
-		if err != nil {
-			return err
-		}
-
-		return nil
-	}
-
-	f, err := os.OpenFile(fileToWrite, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644) //nolint:gomnd
-
-	if err != nil {
-		return err
-	}
-	defer f.Close()
-	_, err = f.WriteString(cmdToOverrideRepo)
-
-	if err != nil {
-		return err
-	}
-
-	return nil
+	return setManagedBlock(fileToWrite, "#", "yarn", cmdToOverrideRepo)
 }
@@ -0,0 +1,189 @@
+package autodetect
+
+import (
+	"bufio"
+	"errors"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// DefaultMaxDepth is the default number of directories projects are searched in below the workspace.
+const DefaultMaxDepth = 3
+
+// excludedDirs are never searched for projects, they hold dependencies and build outputs.
+var excludedDirs = map[string]bool{
+	"node_modules": true,
+	"vendor":       true,
+	"target":       true,
+}
+
+// findProjects returns the build files matching given glob of every project below the working directory.
+// Every project is returned with all its matching build files, like several requirements files of a Python project.
+// Build files of projects nested in another project of the same glob, like modules of Maven projects, are omitted.
+func findProjects(glob string, maxDepth int, excludes []string) ([][]string, error) {
+	ignore, err := readGitignore(".gitignore")
+	if err != nil {
+		return nil, err
+	}
+
+	var matches []string
+
+	err = filepath.WalkDir(".", func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if p == "." {
+			return nil
+		}
+
+		rel := filepath.ToSlash(p)
+		if d.IsDir() {
+			// NOTICE: Hidden directories hold tool configurations and caches, and not projects.
+			if strings.HasPrefix(d.Name(), ".") || excludedDirs[d.Name()] || strings.Count(rel, "/") >= maxDepth ||
+				excluded(rel, excludes) || ignore.match(rel, true) {
+				return filepath.SkipDir
+			}
+
+			return nil
+		}
+
+		if ok, _ := filepath.Match(glob, d.Name()); ok && !ignore.match(rel, false) {
+			matches = append(matches, p)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Shorter paths first, so that enclosing projects precede their nested ones.
+	sort.Slice(matches, func(i, j int) bool {
+		if len(matches[i]) != len(matches[j]) {
+			return len(matches[i]) < len(matches[j])
+		}
+
+		return matches[i] < matches[j]
+	})
+
+	var projects [][]string
+
+	for _, m := range matches {
+		dir := filepath.Dir(m)
+
+		if i := projectIn(dir, projects); i >= 0 {
+			projects[i] = append(projects[i], m)
+			continue
+		}
+
+		if !nested(dir, projects) {
+			projects = append(projects, []string{m})
+		}
+	}
+
+	return projects, nil
+}
+
+// projectIn returns the index of the project of given ones in given directory, or -1.
+func projectIn(dir string, projects [][]string) int {
+	for i, buildFiles := range projects {
+		if filepath.Dir(buildFiles[0]) == dir {
+			return i
+		}
+	}
+
+	return -1
+}
+
+// nested checks whether given directory is within a subdirectory of the directory of one of given projects.
+func nested(dir string, projects [][]string) bool {
+	for _, buildFiles := range projects {
+		parent := filepath.Dir(buildFiles[0])
+		if dir != parent && (parent == "." || strings.HasPrefix(dir, parent+string(filepath.Separator))) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// excluded checks whether given slash separated relative path, or its base name, matches one of given patterns.
+func excluded(rel string, patterns []string) bool {
+	for _, p := range patterns {
+		p = strings.Trim(filepath.ToSlash(p), "/")
+		if ok, _ := path.Match(p, rel); ok {
+			return true
+		}
+
+		if ok, _ := path.Match(p, path.Base(rel)); ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+// gitignore holds the patterns of a .gitignore file, negations and nested .gitignore files are not supported.
+type gitignore []gitignorePattern
+
+type gitignorePattern struct {
+	pattern  string
+	anchored bool
+	dirOnly  bool
+}
+
+func readGitignore(fileName string) (gitignore, error) {
+	f, err := os.Open(fileName)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var ignore gitignore
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, "!") {
+			continue
+		}
+
+		p := gitignorePattern{dirOnly: strings.HasSuffix(line, "/")}
+		line = strings.TrimPrefix(strings.TrimSuffix(line, "/"), "**/")
+		p.anchored = strings.Contains(line, "/")
+		p.pattern = strings.TrimPrefix(line, "/")
+
+		ignore = append(ignore, p)
+	}
+
+	return ignore, scanner.Err()
+}
+
+// match checks whether given slash separated relative path is ignored.
+func (g gitignore) match(rel string, isDir bool) bool {
+	for _, p := range g {
+		if p.dirOnly && !isDir {
+			continue
+		}
+
+		name := path.Base(rel)
+		if p.anchored {
+			name = rel
+		}
+
+		if ok, _ := path.Match(p.pattern, name); ok {
+			return true
+		}
+	}
+
+	return false
+}
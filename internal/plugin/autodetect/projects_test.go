@@ -0,0 +1,117 @@
+package autodetect
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/meltwater/drone-cache/test"
+)
+
+func TestFindProjects(t *testing.T) {
+	tempDir, err := os.MkdirTemp("", "projects-test")
+	test.Ok(t, err)
+	defer os.RemoveAll(tempDir)
+
+	for _, f := range []string{
+		"services/a/pom.xml",
+		"services/a/module/pom.xml", // module of services/a
+		"services/b/pom.xml",
+		"services/b/node_modules/dep/pom.xml",
+		"services/.hidden/pom.xml",
+		"services/ignored/pom.xml",
+		"services/excluded/pom.xml",
+		"libs/deep/er/still/pom.xml", // deeper than the max depth
+		"libs/c/pom.xml",
+	} {
+		test.Ok(t, os.MkdirAll(filepath.Join(tempDir, filepath.Dir(f)), 0755))
+		test.Ok(t, os.WriteFile(filepath.Join(tempDir, f), []byte(testFileContent), 0644))
+	}
+
+	test.Ok(t, os.WriteFile(filepath.Join(tempDir, ".gitignore"), []byte("# comment\nignored/\n"), 0644))
+
+	wd, err := os.Getwd()
+	test.Ok(t, err)
+	test.Ok(t, os.Chdir(tempDir))
+	t.Cleanup(func() { os.Chdir(wd) }) //nolint:errcheck
+
+	projects, err := findProjects(pomFile, DefaultMaxDepth, []string{"services/excluded"})
+	test.Ok(t, err)
+	test.Equals(t, [][]string{
+		{filepath.Join("libs", "c", pomFile)},
+		{filepath.Join("services", "a", pomFile)},
+		{filepath.Join("services", "b", pomFile)},
+	}, projects)
+
+	// Projects within a project at the root are its modules.
+	test.Ok(t, os.WriteFile(pomFile, []byte(testFileContent), 0644))
+
+	projects, err = findProjects(pomFile, DefaultMaxDepth, nil)
+	test.Ok(t, err)
+	test.Equals(t, [][]string{{pomFile}}, projects)
+}
+
+func TestFindProjectsSiblings(t *testing.T) {
+	tempDir, err := os.MkdirTemp("", "projects-test")
+	test.Ok(t, err)
+	defer os.RemoveAll(tempDir)
+
+	for _, f := range []string{
+		"requirements.txt",
+		"requirements-dev.txt",
+		"docs/requirements.txt", // module of the root project
+	} {
+		test.Ok(t, os.MkdirAll(filepath.Join(tempDir, filepath.Dir(f)), 0755))
+		test.Ok(t, os.WriteFile(filepath.Join(tempDir, f), []byte(testFileContent), 0644))
+	}
+
+	wd, err := os.Getwd()
+	test.Ok(t, err)
+	test.Ok(t, os.Chdir(tempDir))
+	t.Cleanup(func() { os.Chdir(wd) }) //nolint:errcheck
+
+	// Build files next to each other belong to the same project.
+	projects, err := findProjects("requirements*.txt", DefaultMaxDepth, nil)
+	test.Ok(t, err)
+	test.Equals(t, [][]string{{"requirements.txt", "requirements-dev.txt"}}, projects)
+
+	test.Ok(t, os.WriteFile("requirements-dev.txt", []byte(testFileContent2), 0644))
+
+	_, buildToolsDetected, hashes, err := DetectDirectoriesToCache(false)
+	test.Ok(t, err)
+
+	test.Equals(t, []string{"pip"}, buildToolsDetected)
+	// Every requirements file is hashed into the key.
+	test.Equals(t, "baab6c16d9143523b7865d46896e45961eb00e74bffac0c4fa2d6dbfd8c26cb7", hashes)
+}
+
+func TestDetectDirectoriesToCacheMonorepo(t *testing.T) {
+	tempDir, err := os.MkdirTemp("", "monorepo-test")
+	test.Ok(t, err)
+	defer os.RemoveAll(tempDir)
+
+	for f, content := range map[string]string{
+		"a/yarn.lock": testFileContent,
+		"b/yarn.lock": testFileContent2,
+	} {
+		test.Ok(t, os.MkdirAll(filepath.Join(tempDir, filepath.Dir(f)), 0755))
+		test.Ok(t, os.WriteFile(filepath.Join(tempDir, f), []byte(content), 0644))
+	}
+
+	wd, err := os.Getwd()
+	test.Ok(t, err)
+	test.Ok(t, os.Chdir(tempDir))
+	t.Cleanup(func() { os.Chdir(wd) }) //nolint:errcheck
+
+	directoriesToCache, buildToolsDetected, hashes, err := DetectDirectoriesToCache(false, WithNodeCache(NodeStore))
+	test.Ok(t, err)
+
+	dir, err := filepath.Abs(".")
+	test.Ok(t, err)
+
+	test.Equals(t, []string{filepath.Join(dir, "a", ".yarn"), filepath.Join(dir, "b", ".yarn")}, directoriesToCache)
+	test.Equals(t, []string{"yarn"}, buildToolsDetected)
+	// Hash of the hashes of both lock files.
+	test.Equals(t, combineHashes([]string{"baab6c16d9143523b7865d46896e4596", "1eb00e74bffac0c4fa2d6dbfd8c26cb7"}), hashes)
+	test.Equals(t, 32, len(hashes))
+}
@@ -0,0 +1,139 @@
+package autodetect
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+
+	"gopkg.in/yaml.v2"
+)
+
+// DefaultRulesFile is the default repository configuration file that declares additional rules.
+const DefaultRulesFile = ".drone-cache.yml"
+
+type rulesFile struct {
+	Autodetect struct {
+		Rules []rule `yaml:"rules"`
+	} `yaml:"autodetect"`
+}
+
+// rule declares a build tool that is not supported out of the box.
+type rule struct {
+	// Tool names the build tool, it names its managed configuration blocks as well.
+	Tool string `yaml:"tool"`
+	// Detect is the glob of the build files of its projects.
+	Detect string `yaml:"detect"`
+	// Cache are directories to cache, relative to its projects and within them.
+	Cache []string `yaml:"cache"`
+	// Hash are files next to the build file that are hashed into the key.
+	Hash []string `yaml:"hash"`
+	// Config are snippets written to configuration files of its projects.
+	Config []configSnippet `yaml:"config"`
+}
+
+type configSnippet struct {
+	// File is the configuration file, relative to the project and within it.
+	File string `yaml:"file"`
+	// Content is a template, {{ .Dir }} is the directory of the project.
+	Content string `yaml:"content"`
+	// Comment starts the comments that delimit the managed block of the snippet, "#" by default.
+	Comment string `yaml:"comment"`
+}
+
+// readRules reads the rules of given configuration file, a missing file declares no rules.
+func readRules(fileName string) ([]buildToolInfo, error) {
+	data, err := os.ReadFile(fileName)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	var f rulesFile
+	if err := yaml.UnmarshalStrict(data, &f); err != nil {
+		return nil, fmt.Errorf("parse autodetect rules of <%s>, %w", fileName, err)
+	}
+
+	var infos []buildToolInfo
+
+	for i, r := range f.Autodetect.Rules {
+		if r.Tool == "" || r.Detect == "" || len(r.Cache) == 0 {
+			return nil, fmt.Errorf("autodetect rule %d of <%s> requires tool, detect and cache", i+1, fileName)
+		}
+
+		// NOTICE: Rules are controlled by the repository, including pull requests, their paths must stay within projects.
+		for _, p := range append(append(append([]string{}, r.Cache...), r.Hash...), configFiles(r.Config)...) {
+			if !filepath.IsLocal(filepath.FromSlash(p)) {
+				return nil, fmt.Errorf("autodetect rule %d of <%s> refers to <%s>, paths must be relative and within the project",
+					i+1, fileName, p)
+			}
+		}
+
+		hashFiles := make([]string, 0, len(r.Hash))
+		for _, h := range r.Hash {
+			hashFiles = append(hashFiles, filepath.FromSlash(h))
+		}
+
+		// NOTICE: Preparers cache a single directory, rules are split into a build tool per directory.
+		for _, c := range r.Cache {
+			infos = append(infos, buildToolInfo{
+				globToDetect: r.Detect,
+				tool:         r.Tool,
+				preparer:     &rulePreparer{rule: r, cache: filepath.FromSlash(c)},
+				hashFiles:    hashFiles,
+			})
+		}
+	}
+
+	return infos, nil
+}
+
+func configFiles(snippets []configSnippet) []string {
+	files := make([]string, 0, len(snippets))
+	for _, c := range snippets {
+		files = append(files, c.File)
+	}
+
+	return files
+}
+
+// rulePreparer writes the configuration snippets of a rule, and returns one of its directories.
+type rulePreparer struct {
+	rule  rule
+	cache string
+}
+
+func (p *rulePreparer) PrepareRepo(dir string) (string, error) {
+	for _, c := range p.rule.Config {
+		comment := c.Comment
+		if comment == "" {
+			comment = "#"
+		}
+
+		fileName := filepath.Join(dir, filepath.FromSlash(c.File))
+		if err := os.MkdirAll(filepath.Dir(fileName), os.ModePerm); err != nil {
+			return "", err
+		}
+
+		tmpl, err := template.New(c.File).Parse(c.Content)
+		if err != nil {
+			return "", fmt.Errorf("parse config snippet of <%s>, %w", c.File, err)
+		}
+
+		var content strings.Builder
+		if err := tmpl.Execute(&content, struct{ Dir string }{dir}); err != nil {
+			return "", fmt.Errorf("render config snippet of <%s>, %w", c.File, err)
+		}
+
+		if err := setManagedBlock(fileName, comment, p.rule.Tool, content.String()); err != nil {
+			return "", err
+		}
+	}
+
+	return filepath.Join(dir, p.cache), nil
+}
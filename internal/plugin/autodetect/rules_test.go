@@ -0,0 +1,84 @@
+package autodetect
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/meltwater/drone-cache/test"
+)
+
+const testRules = `
+autodetect:
+  rules:
+    - tool: conan
+      detect: conanfile.txt
+      cache:
+        - .conan2/p
+        - build
+      hash:
+        - conan.lock
+      config:
+        - file: .conan2/global.conf
+          content: |
+            core.cache:storage_path={{ .Dir }}/.conan2/p
+`
+
+func TestDetectDirectoriesToCacheRules(t *testing.T) {
+	tempDir, err := os.MkdirTemp("", "rules-test")
+	test.Ok(t, err)
+	defer os.RemoveAll(tempDir)
+
+	wd, err := os.Getwd()
+	test.Ok(t, err)
+	test.Ok(t, os.Chdir(tempDir))
+	t.Cleanup(func() { os.Chdir(wd) }) //nolint:errcheck
+
+	test.Ok(t, os.WriteFile(DefaultRulesFile, []byte(testRules), 0644))
+	test.Ok(t, os.WriteFile("conanfile.txt", []byte(testFileContent), 0644))
+	test.Ok(t, os.WriteFile("conan.lock", []byte(testFileContent2), 0644))
+
+	directoriesToCache, buildToolsDetected, hashes, err := DetectDirectoriesToCache(false)
+	test.Ok(t, err)
+
+	dir, err := filepath.Abs(".")
+	test.Ok(t, err)
+
+	test.Equals(t, []string{filepath.Join(dir, ".conan2", "p"), filepath.Join(dir, "build")}, directoriesToCache)
+	test.Equals(t, []string{"conan"}, buildToolsDetected)
+	test.Equals(t, "baab6c16d9143523b7865d46896e45961eb00e74bffac0c4fa2d6dbfd8c26cb7", hashes)
+
+	content, err := os.ReadFile(filepath.Join(".conan2", "global.conf"))
+	test.Ok(t, err)
+	test.Equals(t, "# BEGIN drone-cache autodetect conan\n"+
+		"core.cache:storage_path="+dir+"/.conan2/p\n"+
+		"# END drone-cache autodetect conan\n", string(content))
+}
+
+func TestReadRulesInvalid(t *testing.T) {
+	tempDir, err := os.MkdirTemp("", "rules-test")
+	test.Ok(t, err)
+	defer os.RemoveAll(tempDir)
+
+	for name, rules := range map[string]string{
+		"missing cache":  "autodetect:\n  rules:\n    - tool: conan\n      detect: conanfile.txt\n",
+		"unknown field":  "autodetect:\n  rules:\n    - tool: conan\n      detect: conanfile.txt\n      cache: [build]\n      caches: [x]\n",
+		"absolute cache": "autodetect:\n  rules:\n    - tool: conan\n      detect: conanfile.txt\n      cache: [/root/.conan]\n",
+		"escaping cache": "autodetect:\n  rules:\n    - tool: conan\n      detect: conanfile.txt\n      cache: [build/../../..]\n",
+		"escaping hash":  "autodetect:\n  rules:\n    - tool: conan\n      detect: conanfile.txt\n      cache: [build]\n      hash: [../secret]\n",
+		"absolute config file": "autodetect:\n  rules:\n    - tool: conan\n      detect: conanfile.txt\n      cache: [build]\n" +
+			"      config:\n        - file: /etc/profile\n          content: x\n",
+		"escaping config file": "autodetect:\n  rules:\n    - tool: conan\n      detect: conanfile.txt\n      cache: [build]\n" +
+			"      config:\n        - file: ../.bashrc\n          content: x\n",
+	} {
+		fileName := filepath.Join(tempDir, DefaultRulesFile)
+		test.Ok(t, os.WriteFile(fileName, []byte(rules), 0644))
+
+		_, err := readRules(fileName)
+		test.Assert(t, err != nil, "%s: rules should be invalid", name)
+	}
+
+	rules, err := readRules(filepath.Join(tempDir, "missing.yml"))
+	test.Ok(t, err)
+	test.Equals(t, 0, len(rules))
+}
@@ -0,0 +1,63 @@
+package plugin
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/meltwater/drone-cache/internal/metadata"
+)
+
+const tagRefPrefix = "refs/tags/"
+
+// Condition restricts the runs a mode is executed for, empty fields match every run.
+type Condition struct {
+	Branches []string
+	Events   []string
+	Status   []string
+	Tags     []string
+}
+
+// skipReason returns why the run described by given metadata does not satisfy the condition, or empty if it does.
+func (c Condition) skipReason(m metadata.Metadata) string {
+	if len(c.Events) > 0 && !containsFold(c.Events, m.Build.Event) {
+		return fmt.Sprintf("build event <%s> is not one of %v", m.Build.Event, c.Events)
+	}
+
+	if len(c.Status) > 0 && !containsFold(c.Status, m.Build.Status) {
+		return fmt.Sprintf("build status <%s> is not one of %v", m.Build.Status, c.Status)
+	}
+
+	if len(c.Branches) == 0 && len(c.Tags) == 0 {
+		return ""
+	}
+
+	// NOTICE: Branches and tags are alternatives, a tag build has no branch to match and the other way around.
+	if len(c.Branches) > 0 && matchAny(c.Branches, m.Commit.Branch) {
+		return ""
+	}
+
+	if tag, ok := strings.CutPrefix(m.Commit.Ref, tagRefPrefix); ok && len(c.Tags) > 0 && matchAny(c.Tags, tag) {
+		return ""
+	}
+
+	var reasons []string
+	if len(c.Branches) > 0 {
+		reasons = append(reasons, fmt.Sprintf("branch <%s> does not match %v", m.Commit.Branch, c.Branches))
+	}
+
+	if len(c.Tags) > 0 {
+		reasons = append(reasons, fmt.Sprintf("ref <%s> is not a tag matching %v", m.Commit.Ref, c.Tags))
+	}
+
+	return strings.Join(reasons, " and ")
+}
+
+func containsFold(values []string, s string) bool {
+	for _, v := range values {
+		if strings.EqualFold(strings.TrimSpace(v), s) {
+			return true
+		}
+	}
+
+	return false
+}
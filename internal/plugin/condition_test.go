@@ -0,0 +1,98 @@
+package plugin
+
+import (
+	"testing"
+
+	"github.com/meltwater/drone-cache/internal/metadata"
+	"github.com/meltwater/drone-cache/test"
+)
+
+func TestConditionSkipReason(t *testing.T) {
+	t.Parallel()
+
+	push := metadata.Metadata{
+		Build:  metadata.Build{Event: "push", Status: "success"},
+		Commit: metadata.Commit{Branch: "release/1.2", Ref: "refs/heads/release/1.2"},
+	}
+	tag := metadata.Metadata{
+		Build:  metadata.Build{Event: "tag", Status: "success"},
+		Commit: metadata.Commit{Branch: "main", Ref: "refs/tags/v1.2.0"},
+	}
+
+	for _, tc := range []struct {
+		name string
+		cond Condition
+		m    metadata.Metadata
+		want string
+	}{
+		{
+			name: "empty condition matches every run",
+			m:    push,
+		},
+		{
+			name: "matching branch glob and event",
+			cond: Condition{Branches: []string{"main", "release/*"}, Events: []string{"push"}},
+			m:    push,
+		},
+		{
+			name: "event mismatch",
+			cond: Condition{Events: []string{"push"}},
+			m:    tag,
+			want: "build event <tag> is not one of [push]",
+		},
+		{
+			name: "failed build",
+			cond: Condition{Status: []string{"success"}},
+			m: metadata.Metadata{
+				Build: metadata.Build{Event: "push", Status: "failure"},
+			},
+			want: "build status <failure> is not one of [success]",
+		},
+		{
+			name: "branch mismatch",
+			cond: Condition{Branches: []string{"main"}},
+			m:    push,
+			want: "branch <release/1.2> does not match [main]",
+		},
+		{
+			name: "matching tag",
+			cond: Condition{Tags: []string{"v*"}},
+			m:    tag,
+		},
+		{
+			name: "tag mismatch",
+			cond: Condition{Tags: []string{"release-*"}},
+			m:    tag,
+			want: "ref <refs/tags/v1.2.0> is not a tag matching [release-*]",
+		},
+		{
+			name: "not a tag",
+			cond: Condition{Tags: []string{"v*"}},
+			m:    push,
+			want: "ref <refs/heads/release/1.2> is not a tag matching [v*]",
+		},
+		{
+			name: "tag build with branches and tags",
+			cond: Condition{Branches: []string{"release/*"}, Tags: []string{"v*"}},
+			m:    tag,
+		},
+		{
+			name: "branch build with branches and tags",
+			cond: Condition{Branches: []string{"release/*"}, Tags: []string{"v*"}},
+			m:    push,
+		},
+		{
+			name: "neither branch nor tag",
+			cond: Condition{Branches: []string{"develop"}, Tags: []string{"release-*"}},
+			m:    tag,
+			want: "branch <main> does not match [develop] and ref <refs/tags/v1.2.0> is not a tag matching [release-*]",
+		},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			test.Equals(t, tc.want, tc.cond.skipReason(tc.m))
+		})
+	}
+}
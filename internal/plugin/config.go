@@ -17,28 +17,58 @@ type Config struct {
 	Backend          string
 	CacheKeyTemplate string
 	MetricsFile      string
+	StateFile        string
 	RemoteRoot       string
 	LocalRoot        string
 	AccountID        string
 
 	// Modes
-	Debug               bool
-	Rebuild             bool
-	Restore             bool
-	AutoDetect          bool
-	AutoDetectEarlyExit bool
+	Debug                 bool
+	Rebuild               bool
+	Restore               bool
+	AutoDetect            bool
+	AutoDetectEarlyExit   bool
+	AutoDetectCargoTarget bool
+	AutoDetectNodeCache   string
+	AutoDetectMaxDepth    int
+	AutoDetectExcludes    []string
+	AutoDetectCleanup     bool
+	AutoDetectGoCacheTrim time.Duration
+	AutoDetectRules       string
+	// AutoDetectBazelDiskCache is a human readable size, e.g. 10GB.
+	AutoDetectBazelDiskCache string
 
 	// Optional
 	SkipSymlinks               bool
+	SymlinkPolicy              string
 	Override                   bool
 	FailRestoreIfKeyNotPresent bool
 	CompressionLevel           int
+	CompressionConcurrency     int
+	CompressionWindowSize      int
+	AdaptiveCompression        bool
+	ZipCompression             string
+	ExtractConcurrency         int
 	StorageOperationTimeout    time.Duration
 	EnableCacheKeySeparator    bool
 	StrictKeyMatching          bool `envconfig:"PLUGIN_STRICT_KEY_MATCHING" default:"true"`
 
 	Mount []string
 
+	// Limits
+	Limits LimitsConfig
+
+	// Restore
+	Attributes AttributesConfig
+
+	// Conditions
+	RebuildWhen Condition
+	RestoreWhen Condition
+
+	// Security
+	Policy   PolicyConfig
+	Manifest ManifestConfig
+
 	// Backend
 	S3         s3.Config
 	FileSystem filesystem.Config
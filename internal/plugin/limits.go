@@ -0,0 +1,51 @@
+package plugin
+
+import (
+	"fmt"
+
+	"github.com/dustin/go-humanize"
+
+	"github.com/meltwater/drone-cache/archive/common"
+)
+
+// LimitsConfig configures limits that archives are checked against on rebuild and restore.
+// Sizes are human readable, e.g. 10GB or 512MiB, empty values and zero disable the limit.
+type LimitsConfig struct {
+	MaxArchiveSize      string
+	MaxUncompressedSize string
+	MaxEntries          int64
+	MaxCompressionRatio float64
+}
+
+// limits parses the configured limits.
+func (c LimitsConfig) limits() (common.Limits, error) {
+	archiveSize, err := parseSize(c.MaxArchiveSize)
+	if err != nil {
+		return common.Limits{}, fmt.Errorf("parse max archive size, %w", err)
+	}
+
+	uncompressedSize, err := parseSize(c.MaxUncompressedSize)
+	if err != nil {
+		return common.Limits{}, fmt.Errorf("parse max uncompressed size, %w", err)
+	}
+
+	return common.Limits{
+		MaxArchiveSize:      archiveSize,
+		MaxUncompressedSize: uncompressedSize,
+		MaxEntries:          c.MaxEntries,
+		MaxRatio:            c.MaxCompressionRatio,
+	}, nil
+}
+
+func parseSize(s string) (int64, error) {
+	if s == "" {
+		return 0, nil
+	}
+
+	b, err := humanize.ParseBytes(s)
+	if err != nil {
+		return 0, err
+	}
+
+	return int64(b), nil
+}
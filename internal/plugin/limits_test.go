@@ -0,0 +1,33 @@
+package plugin
+
+import (
+	"testing"
+
+	"github.com/meltwater/drone-cache/archive/common"
+	"github.com/meltwater/drone-cache/test"
+)
+
+func TestLimits(t *testing.T) {
+	t.Parallel()
+
+	l, err := LimitsConfig{}.limits()
+	test.Ok(t, err)
+	test.Equals(t, common.Limits{}, l)
+
+	l, err = LimitsConfig{
+		MaxArchiveSize:      "10GB",
+		MaxUncompressedSize: "512MiB",
+		MaxEntries:          1000,
+		MaxCompressionRatio: 100,
+	}.limits()
+	test.Ok(t, err)
+	test.Equals(t, common.Limits{
+		MaxArchiveSize:      10 * 1000 * 1000 * 1000,
+		MaxUncompressedSize: 512 << 20,
+		MaxEntries:          1000,
+		MaxRatio:            100,
+	}, l)
+
+	_, err = LimitsConfig{MaxArchiveSize: "lots"}.limits()
+	test.NotOk(t, err)
+}
@@ -0,0 +1,81 @@
+package plugin
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/meltwater/drone-cache/cache"
+	"github.com/meltwater/drone-cache/cache/manifest"
+	"github.com/meltwater/drone-cache/internal/metadata"
+
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+)
+
+// ManifestConfig configures signing of cache manifests on rebuild and their verification on restore.
+type ManifestConfig struct {
+	SigningKey string
+	HMACSecret string
+	PublicKeys []string
+	Verify     bool
+}
+
+// manifestOptions returns cache options to sign manifests on rebuild, or to verify them on restore.
+func manifestOptions(logger log.Logger, cfg Config, m metadata.Metadata) ([]cache.Option, error) {
+	mc := cfg.Manifest
+
+	var options []cache.Option
+
+	if cfg.Rebuild && (mc.SigningKey != "" || mc.HMACSecret != "") {
+		if mc.SigningKey != "" && mc.HMACSecret != "" {
+			return nil, errors.New("manifest signing key and hmac secret are mutually exclusive, please set only one of them")
+		}
+
+		// NOTICE: Manifests of untrusted runs are not signed, even if a key leaked into their environment.
+		// Their archives are refused by verifying restores.
+		if reason := untrustedReason(cfg.Policy, m); reason != "" {
+			level.Warn(logger).Log("msg", "manifest signing skipped because "+reason)
+			return options, nil
+		}
+
+		var (
+			s   manifest.Signer
+			err error
+		)
+
+		if mc.SigningKey != "" {
+			s, err = manifest.NewEd25519Signer(mc.SigningKey)
+		} else {
+			s, err = manifest.NewHMACSigner(mc.HMACSecret)
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		options = append(options,
+			cache.WithSigner(s),
+			cache.WithProvenance(manifest.Provenance{CommitSHA: m.Commit.Sha, BuildLink: m.Build.Link}),
+		)
+	}
+
+	if cfg.Restore && (mc.Verify || len(mc.PublicKeys) > 0) {
+		// NOTICE: Verifying with a HMAC secret requires holding it, which allows signing forged manifests as well.
+		// It is only accepted in runs trusted by the write policy configuration.
+		if mc.HMACSecret != "" {
+			if reason := untrustedReason(cfg.Policy, m); reason != "" {
+				return nil, fmt.Errorf("manifest hmac secret refused because %s, anyone holding it can forge manifests, "+
+					"sign with an ed25519 key and verify with its manifest public keys instead", reason)
+			}
+		}
+
+		v, err := manifest.NewVerifier(mc.PublicKeys, []string{mc.HMACSecret})
+		if err != nil {
+			return nil, err
+		}
+
+		options = append(options, cache.WithVerifier(v))
+	}
+
+	return options, nil
+}
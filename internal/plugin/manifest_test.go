@@ -0,0 +1,46 @@
+package plugin
+
+import (
+	"testing"
+
+	"github.com/go-kit/kit/log"
+
+	"github.com/meltwater/drone-cache/internal/metadata"
+	"github.com/meltwater/drone-cache/test"
+)
+
+func TestManifestOptionsHMACVerification(t *testing.T) {
+	t.Parallel()
+
+	cfg := Config{
+		Restore:  true,
+		Manifest: ManifestConfig{HMACSecret: "s3cr3t", Verify: true},
+		Policy:   PolicyConfig{UntrustedEvents: []string{"pull_request"}},
+	}
+
+	options, err := manifestOptions(log.NewNopLogger(), cfg, metadata.Metadata{Build: metadata.Build{Event: "push"}})
+	test.Ok(t, err)
+	test.Equals(t, 1, len(options))
+
+	_, err = manifestOptions(log.NewNopLogger(), cfg, metadata.Metadata{Build: metadata.Build{Event: "pull_request"}})
+	test.NotOk(t, err)
+}
+
+func TestManifestOptionsSigning(t *testing.T) {
+	t.Parallel()
+
+	cfg := Config{
+		Rebuild:  true,
+		Manifest: ManifestConfig{HMACSecret: "s3cr3t"},
+		Policy:   PolicyConfig{UntrustedEvents: []string{"pull_request"}},
+	}
+
+	options, err := manifestOptions(log.NewNopLogger(), cfg, metadata.Metadata{Build: metadata.Build{Event: "push"}})
+	test.Ok(t, err)
+	test.Equals(t, 2, len(options))
+
+	// Untrusted runs do not sign, their archives can not pass as trusted ones.
+	options, err = manifestOptions(log.NewNopLogger(), cfg, metadata.Metadata{Build: metadata.Build{Event: "pull_request"}})
+	test.Ok(t, err)
+	test.Equals(t, 0, len(options))
+}
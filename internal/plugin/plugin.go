@@ -7,10 +7,12 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/meltwater/drone-cache/internal/plugin/autodetect"
 
 	"github.com/meltwater/drone-cache/archive"
+	"github.com/meltwater/drone-cache/archive/common"
 	"github.com/meltwater/drone-cache/cache"
 	"github.com/meltwater/drone-cache/internal/metadata"
 	"github.com/meltwater/drone-cache/key"
@@ -66,6 +68,32 @@ func (p *Plugin) Exec() error { // nolint:funlen
 		return errors.New("rebuild and restore are mutually exclusive, please set only one of them")
 	}
 
+	if cfg.AutoDetectCleanup {
+		if cfg.Restore {
+			return errors.New("auto detect cleanup restores configuration files after the build, it cannot be used with restore")
+		}
+
+		defer p.cleanupAutoDetect()
+
+		if !cfg.Rebuild {
+			return nil
+		}
+	}
+
+	if cfg.Rebuild {
+		if reason := cfg.RebuildWhen.skipReason(p.Metadata); reason != "" {
+			level.Info(p.logger).Log("msg", "rebuild skipped because "+reason)
+			return nil
+		}
+	}
+
+	if cfg.Restore {
+		if reason := cfg.RestoreWhen.skipReason(p.Metadata); reason != "" {
+			level.Info(p.logger).Log("msg", "restore skipped because "+reason)
+			return nil
+		}
+	}
+
 	var localRoot string
 	if p.Config.LocalRoot != "" {
 		localRoot = filepath.Clean(p.Config.LocalRoot)
@@ -86,14 +114,65 @@ func (p *Plugin) Exec() error { // nolint:funlen
 	// 	options = append(options, cache.WithNamespace(p.Metadata.Repo.Name))
 	// }
 
-	var generator key.Generator
+	if cfg.Rebuild || cfg.Restore {
+		decision, err := decideWrite(p.logger, cfg.Policy, p.Metadata)
+		if err != nil {
+			return fmt.Errorf("evaluate write policy, %w", err)
+		}
+
+		switch {
+		case cfg.Rebuild && !decision.allowed:
+			level.Warn(p.logger).Log("msg", "rebuild refused by read-only write policy, skipping cache rebuild", "reason", decision.reason)
+			return nil
+		case cfg.Rebuild && decision.namespace != "":
+			level.Warn(p.logger).Log("msg", "rebuild downgraded by scoped write policy, writing to scoped namespace",
+				"namespace", decision.namespace, "reason", decision.reason)
+			options = append(options, cache.WithNamespace(decision.namespace))
+		case cfg.Restore && decision.namespace != "":
+			// Scoped runs read back what they wrote, and the shared keys until they wrote anything.
+			level.Info(p.logger).Log("msg", "restoring from scoped namespace, falling back to shared keys",
+				"namespace", decision.namespace, "reason", decision.reason)
+			options = append(options, cache.WithNamespace(decision.namespace), cache.WithFallbackNamespaces(""))
+		}
+	}
+
+	var (
+		generator key.Generator
+		excludes  []string
+	)
 
 	switch {
 	case cfg.AutoDetect:
 		{
 			var toolDetected, keyOverriden bool = false, false
 			pathOverridden := len(p.Config.Mount) > 0
-			dirs, buildTools, cacheKey, err := autodetect.DetectDirectoriesToCache(pathOverridden)
+
+			// Stale build cache entries are only removed before they are uploaded.
+			var (
+				goCacheTrim        time.Duration
+				bazelDiskCacheSize int64
+			)
+
+			if cfg.Rebuild {
+				goCacheTrim = cfg.AutoDetectGoCacheTrim
+
+				size, err := parseSize(cfg.AutoDetectBazelDiskCache)
+				if err != nil {
+					return fmt.Errorf("parse bazel disk cache size, %w", err)
+				}
+
+				bazelDiskCacheSize = size
+			}
+
+			dirs, buildTools, cacheKey, err := autodetect.DetectDirectoriesToCache(pathOverridden,
+				autodetect.WithCargoTarget(cfg.AutoDetectCargoTarget),
+				autodetect.WithNodeCache(cfg.AutoDetectNodeCache),
+				autodetect.WithMaxDepth(cfg.AutoDetectMaxDepth),
+				autodetect.WithExcludes(cfg.AutoDetectExcludes),
+				autodetect.WithGoCacheTrim(goCacheTrim),
+				autodetect.WithRulesFile(cfg.AutoDetectRules),
+				autodetect.WithBazelDiskCacheSize(bazelDiskCacheSize),
+			)
 			if err != nil {
 				return fmt.Errorf("autodetect enabled but failed to detect, falling back to default, %w", err)
 			}
@@ -105,8 +184,25 @@ func (p *Plugin) Exec() error { // nolint:funlen
 			} else {
 				p.logger.Log("msg", "no supported build tool detected") //nolint: errcheck
 			}
+			for _, dir := range dirs {
+				if config, ok := autodetect.PipConfigFile(dir); ok {
+					level.Warn(p.logger).Log("msg", "pip only uses the cached directory if PIP_CONFIG_FILE points to the written configuration in build steps",
+						"config", config)
+				}
+				if env, ok := autodetect.GoEnvFile(dir); ok {
+					level.Warn(p.logger).Log("msg", "go only uses the cached directory if GOENV points to the written env file in build steps",
+						"env", env)
+				}
+				if autodetect.IsCargoHome(dir) {
+					level.Warn(p.logger).Log("msg", "cargo only uses the cached registry and git checkouts if CARGO_HOME points to the cached directory in build steps",
+						"dir", dir)
+				}
+			}
 			if !pathOverridden {
 				p.Config.Mount = dirs
+				for _, dir := range dirs {
+					excludes = append(excludes, autodetect.CargoExcludes(dir)...)
+				}
 				options = append(options, cache.WithGracefulDetect(true))
 			} else {
 				options = append(options, cache.WithGracefulDetect(false))
@@ -165,12 +261,47 @@ func (p *Plugin) Exec() error { // nolint:funlen
 		}
 	}
 
+	manifestOpts, err := manifestOptions(p.logger, cfg, p.Metadata)
+	if err != nil {
+		return fmt.Errorf("configure cache manifests, %w", err)
+	}
+
+	options = append(options, manifestOpts...)
+
 	options = append(options, cache.WithOverride(p.Config.Override),
-		cache.WithFailRestoreIfKeyNotPresent(p.Config.FailRestoreIfKeyNotPresent), 
+		cache.WithFailRestoreIfKeyNotPresent(p.Config.FailRestoreIfKeyNotPresent),
 		cache.WithEnableCacheKeySeparator(p.Config.EnableCacheKeySeparator),
-		cache.WithStrictKeyMatching(p.Config.StrictKeyMatching))
+		cache.WithStrictKeyMatching(p.Config.StrictKeyMatching),
+		cache.WithStateFile(p.Config.StateFile))
+
+	// 2. Initialize archive and storage backend.
+	limits, err := cfg.Limits.limits()
+	if err != nil {
+		return fmt.Errorf("configure archive limits, %w", err)
+	}
+
+	attrs, err := cfg.Attributes.attributes()
+	if err != nil {
+		return fmt.Errorf("configure restored attributes, %w", err)
+	}
+
+	a, err := archive.FromFormat(p.logger, localRoot, cfg.ArchiveFormat,
+		archive.WithSkipSymlinks(cfg.SkipSymlinks),
+		archive.WithSymlinkPolicy(common.SymlinkPolicy(cfg.SymlinkPolicy)),
+		archive.WithCompressionLevel(cfg.CompressionLevel),
+		archive.WithConcurrency(cfg.CompressionConcurrency),
+		archive.WithWindowSize(cfg.CompressionWindowSize),
+		archive.WithAdaptiveCompression(cfg.AdaptiveCompression),
+		archive.WithZipCompression(cfg.ZipCompression),
+		archive.WithExtractConcurrency(cfg.ExtractConcurrency),
+		archive.WithLimits(limits),
+		archive.WithAttributes(attrs),
+		archive.WithExcludes(excludes),
+	)
+	if err != nil {
+		return fmt.Errorf("initialize archive, %w", err)
+	}
 
-	// 2. Initialize storage backend.
 	b, err := backend.FromConfig(p.logger, cfg.Backend, backend.Config{
 		Debug:      cfg.Debug,
 		Azure:      cfg.Azure,
@@ -187,10 +318,7 @@ func (p *Plugin) Exec() error { // nolint:funlen
 	// 3. Initialize cache.
 	c := cache.New(p.logger,
 		storage.New(p.logger, b, cfg.StorageOperationTimeout),
-		archive.FromFormat(p.logger, localRoot, cfg.ArchiveFormat,
-			archive.WithSkipSymlinks(cfg.SkipSymlinks),
-			archive.WithCompressionLevel(cfg.CompressionLevel),
-		),
+		a,
 		generator,
 		cfg.Backend,
 		cfg.AccountID,
@@ -219,3 +347,15 @@ func (p *Plugin) Exec() error { // nolint:funlen
 
 	return nil
 }
+
+// cleanupAutoDetect restores configuration files changed by auto detection to their original content.
+func (p *Plugin) cleanupAutoDetect() {
+	restored, err := autodetect.Cleanup()
+	if err != nil {
+		level.Error(p.logger).Log("msg", "restore configuration files changed by auto detection", "err", err)
+	}
+
+	for _, f := range restored {
+		level.Debug(p.logger).Log("msg", "configuration file restored", "file", f)
+	}
+}
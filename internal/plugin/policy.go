@@ -0,0 +1,112 @@
+package plugin
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/go-kit/kit/log"
+
+	"github.com/meltwater/drone-cache/internal/metadata"
+	keygen "github.com/meltwater/drone-cache/key/generator"
+)
+
+const (
+	// WritePolicyOpen allows every run to write to any cache key.
+	WritePolicyOpen = "open"
+	// WritePolicyScoped redirects writes of untrusted runs into a namespace of their own.
+	WritePolicyScoped = "scoped"
+	// WritePolicyReadOnly refuses writes of untrusted runs, they can only restore.
+	WritePolicyReadOnly = "read-only"
+
+	// DefaultWritePolicy keeps untrusted runs, like pull requests of forks, from writing to shared cache keys.
+	DefaultWritePolicy = WritePolicyScoped
+	// DefaultScopedNamespace is the namespace template for writes of untrusted runs in scoped mode.
+	DefaultScopedNamespace = "{{ if .Build.PullRequest }}pr-{{ .Build.PullRequest }}{{ else }}{{ .Build.Event }}-{{ .Commit.Branch }}{{ end }}" // nolint:lll
+)
+
+// PolicyConfig configures which runs are allowed to write to shared cache keys.
+type PolicyConfig struct {
+	WritePolicy        string
+	ScopedNamespace    string
+	TrustedBranches    []string
+	UntrustedEvents    []string
+	RequireTrustedRepo bool
+}
+
+// writeDecision is the outcome of evaluating the write policy for the current run.
+type writeDecision struct {
+	allowed   bool
+	namespace string
+	reason    string
+}
+
+// decideWrite evaluates the write policy against given build metadata.
+func decideWrite(logger log.Logger, cfg PolicyConfig, m metadata.Metadata) (writeDecision, error) {
+	policy := cfg.WritePolicy
+	if policy == "" {
+		policy = DefaultWritePolicy
+	}
+
+	switch policy {
+	case WritePolicyOpen, WritePolicyScoped, WritePolicyReadOnly:
+	default:
+		return writeDecision{}, fmt.Errorf("unknown write policy <%s>, (%s, %s, %s)",
+			policy, WritePolicyOpen, WritePolicyScoped, WritePolicyReadOnly)
+	}
+
+	reason := untrustedReason(cfg, m)
+	if policy == WritePolicyOpen || reason == "" {
+		return writeDecision{allowed: true}, nil
+	}
+
+	if policy == WritePolicyReadOnly {
+		return writeDecision{allowed: false, reason: reason}, nil
+	}
+
+	tmpl := cfg.ScopedNamespace
+	if tmpl == "" {
+		tmpl = DefaultScopedNamespace
+	}
+
+	ns, err := keygen.NewMetadata(logger, tmpl, m).Generate()
+	if err != nil {
+		return writeDecision{}, fmt.Errorf("scoped namespace, %w", err)
+	}
+
+	ns = filepath.ToSlash(filepath.Clean(strings.TrimSpace(ns)))
+	if ns == "." || ns == "/" || ns == ".." || strings.HasPrefix(ns, "../") || filepath.IsAbs(ns) {
+		return writeDecision{}, fmt.Errorf("scoped namespace <%s> must be a relative path", ns)
+	}
+
+	return writeDecision{allowed: true, namespace: ns, reason: reason}, nil
+}
+
+// untrustedReason returns why the current run is not trusted to write to shared keys, or empty if it is.
+func untrustedReason(cfg PolicyConfig, m metadata.Metadata) string {
+	if containsFold(cfg.UntrustedEvents, m.Build.Event) {
+		return fmt.Sprintf("build event <%s> is untrusted", m.Build.Event)
+	}
+
+	if cfg.RequireTrustedRepo && !m.Repo.Trusted {
+		return "repository is not trusted"
+	}
+
+	if len(cfg.TrustedBranches) > 0 && !matchAny(cfg.TrustedBranches, m.Commit.Branch) {
+		return fmt.Sprintf("branch <%s> is not a trusted branch", m.Commit.Branch)
+	}
+
+	return ""
+}
+
+// matchAny reports whether given value matches any of the glob patterns.
+func matchAny(patterns []string, s string) bool {
+	for _, p := range patterns {
+		if ok, err := path.Match(strings.TrimSpace(p), s); err == nil && ok {
+			return true
+		}
+	}
+
+	return false
+}
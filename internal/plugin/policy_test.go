@@ -0,0 +1,124 @@
+package plugin
+
+import (
+	"testing"
+
+	"github.com/go-kit/kit/log"
+
+	"github.com/meltwater/drone-cache/internal/metadata"
+	"github.com/meltwater/drone-cache/test"
+)
+
+func TestDecideWrite(t *testing.T) {
+	t.Parallel()
+
+	pr := metadata.Metadata{
+		Build:  metadata.Build{Event: "pull_request", PullRequest: 42},
+		Commit: metadata.Commit{Branch: "feature/x"},
+	}
+	push := metadata.Metadata{
+		Build:  metadata.Build{Event: "push"},
+		Commit: metadata.Commit{Branch: "main"},
+		Repo:   metadata.Repo{Trusted: true},
+	}
+	untrusted := []string{"pull_request"}
+
+	for _, tc := range []struct {
+		name string
+		cfg  PolicyConfig
+		m    metadata.Metadata
+		want writeDecision
+	}{
+		{
+			name: "open allows untrusted event",
+			cfg:  PolicyConfig{WritePolicy: WritePolicyOpen, UntrustedEvents: untrusted},
+			m:    pr,
+			want: writeDecision{allowed: true},
+		},
+		{
+			name: "empty policy defaults to scoped",
+			cfg:  PolicyConfig{UntrustedEvents: untrusted},
+			m:    pr,
+			want: writeDecision{allowed: true, namespace: "pr-42", reason: "build event <pull_request> is untrusted"},
+		},
+		{
+			name: "read-only refuses untrusted event",
+			cfg:  PolicyConfig{WritePolicy: WritePolicyReadOnly, UntrustedEvents: untrusted},
+			m:    pr,
+			want: writeDecision{reason: "build event <pull_request> is untrusted"},
+		},
+		{
+			name: "read-only allows trusted push",
+			cfg:  PolicyConfig{WritePolicy: WritePolicyReadOnly, UntrustedEvents: untrusted, RequireTrustedRepo: true},
+			m:    push,
+			want: writeDecision{allowed: true},
+		},
+		{
+			name: "scoped downgrades pull request",
+			cfg:  PolicyConfig{WritePolicy: WritePolicyScoped, UntrustedEvents: untrusted},
+			m:    pr,
+			want: writeDecision{allowed: true, namespace: "pr-42", reason: "build event <pull_request> is untrusted"},
+		},
+		{
+			name: "scoped downgrades untrusted branch",
+			cfg:  PolicyConfig{WritePolicy: WritePolicyScoped, TrustedBranches: []string{"main", "release/*"}},
+			m: metadata.Metadata{
+				Build:  metadata.Build{Event: "push"},
+				Commit: metadata.Commit{Branch: "feature"},
+			},
+			want: writeDecision{allowed: true, namespace: "push-feature", reason: "branch <feature> is not a trusted branch"},
+		},
+		{
+			name: "trusted branch glob",
+			cfg:  PolicyConfig{WritePolicy: WritePolicyReadOnly, TrustedBranches: []string{"main", "release/*"}},
+			m: metadata.Metadata{
+				Build:  metadata.Build{Event: "push"},
+				Commit: metadata.Commit{Branch: "release/1.0"},
+			},
+			want: writeDecision{allowed: true},
+		},
+		{
+			name: "untrusted repository",
+			cfg:  PolicyConfig{WritePolicy: WritePolicyReadOnly, RequireTrustedRepo: true},
+			m:    metadata.Metadata{Build: metadata.Build{Event: "push"}},
+			want: writeDecision{reason: "repository is not trusted"},
+		},
+		{
+			name: "custom scoped namespace",
+			cfg: PolicyConfig{
+				WritePolicy:     WritePolicyScoped,
+				UntrustedEvents: untrusted,
+				ScopedNamespace: "untrusted/{{ .Commit.Branch }}",
+			},
+			m:    pr,
+			want: writeDecision{allowed: true, namespace: "untrusted/feature/x", reason: "build event <pull_request> is untrusted"},
+		},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := decideWrite(log.NewNopLogger(), tc.cfg, tc.m)
+			test.Ok(t, err)
+			test.Equals(t, tc.want.allowed, got.allowed)
+			test.Equals(t, tc.want.namespace, got.namespace)
+			test.Equals(t, tc.want.reason, got.reason)
+		})
+	}
+}
+
+func TestDecideWriteInvalid(t *testing.T) {
+	t.Parallel()
+
+	m := metadata.Metadata{Build: metadata.Build{Event: "pull_request"}}
+
+	_, err := decideWrite(log.NewNopLogger(), PolicyConfig{WritePolicy: "unknown"}, m)
+	test.NotOk(t, err)
+
+	_, err = decideWrite(log.NewNopLogger(), PolicyConfig{
+		WritePolicy:     WritePolicyScoped,
+		UntrustedEvents: []string{"pull_request"},
+		ScopedNamespace: "../{{ .Build.Event }}",
+	}, m)
+	test.NotOk(t, err)
+}
@@ -9,6 +9,7 @@ import (
 	"github.com/meltwater/drone-cache/internal"
 	"github.com/meltwater/drone-cache/internal/metadata"
 	"github.com/meltwater/drone-cache/internal/plugin"
+	"github.com/meltwater/drone-cache/internal/plugin/autodetect"
 	"github.com/meltwater/drone-cache/storage"
 	"github.com/meltwater/drone-cache/storage/backend"
 	"github.com/meltwater/drone-cache/storage/backend/azure"
@@ -30,14 +31,23 @@ var (
 	date    = "unknown"
 )
 
-// nolint:funlen
 func main() {
 	app := cli.NewApp()
 	app.Name = "Drone cache plugin"
 	app.Usage = "Drone cache plugin"
 	app.Action = run
 	app.Version = version
-	app.Flags = []cli.Flag{
+	app.Commands = commands()
+	app.Flags = flags()
+
+	if err := app.Run(os.Args); err != nil {
+		stdlog.Fatalf("%#v", err)
+	}
+}
+
+// nolint:funlen
+func flags() []cli.Flag {
+	return []cli.Flag{
 		// Logger flags
 
 		&cli.StringFlag{
@@ -190,6 +200,11 @@ func main() {
 			Usage:   "build link",
 			EnvVars: []string{"DRONE_BUILD_LINK"},
 		},
+		&cli.IntFlag{
+			Name:    "build.pull-request, bpr",
+			Usage:   "build pull request number",
+			EnvVars: []string{"DRONE_PULL_REQUEST"},
+		},
 		&cli.StringFlag{
 			Name:    "build.deploy, db",
 			Usage:   "build deployment target",
@@ -257,6 +272,11 @@ func main() {
 			Usage:   "cache file to use for generating cache file metrics",
 			EnvVars: []string{"PLUGIN_CACHE_INTEL_METRICS_FILE"},
 		},
+		&cli.StringFlag{
+			Name:    "state-file",
+			Usage:   "local file to record fingerprints of restored directories in, rebuilds skip uploading unchanged directories",
+			EnvVars: []string{"PLUGIN_STATE_FILE"},
+		},
 		&cli.StringFlag{
 			Name:    "remote-root, rr",
 			Usage:   "remote root directory to contain all the cache files created (default repo.name)",
@@ -285,6 +305,51 @@ func main() {
 			Value:   false,
 			EnvVars: []string{"PLUGIN_AUTO_CACHE_EARLY_EXIT"},
 		},
+		&cli.BoolFlag{
+			Name:    "auto-detect-cargo-target",
+			Usage:   "cache the target directory of auto detected Cargo builds along with their dependencies",
+			Value:   false,
+			EnvVars: []string{"PLUGIN_AUTO_CACHE_CARGO_TARGET"},
+		},
+		&cli.StringFlag{
+			Name:    "auto-detect-node-cache",
+			Usage:   "what to cache for auto detected Node projects, node_modules or the package manager store (node_modules, store)",
+			Value:   "node_modules",
+			EnvVars: []string{"PLUGIN_AUTO_CACHE_NODE_CACHE"},
+		},
+		&cli.IntFlag{
+			Name:    "auto-detect-max-depth",
+			Usage:   "number of directories below the workspace that are searched for projects to auto detect",
+			Value:   autodetect.DefaultMaxDepth,
+			EnvVars: []string{"PLUGIN_AUTO_CACHE_MAX_DEPTH"},
+		},
+		&cli.StringSliceFlag{
+			Name:    "auto-detect-exclude",
+			Usage:   "glob patterns of directories that are not searched for projects to auto detect",
+			EnvVars: []string{"PLUGIN_AUTO_CACHE_EXCLUDE"},
+		},
+		&cli.BoolFlag{
+			Name:    "auto-detect-cleanup",
+			Usage:   "restore configuration files changed by auto detection to their original content, after rebuilding",
+			Value:   false,
+			EnvVars: []string{"PLUGIN_AUTO_CACHE_CLEANUP"},
+		},
+		&cli.DurationFlag{
+			Name:    "auto-detect-go-cache-trim",
+			Usage:   "remove Go build cache entries that were not used for given duration, before rebuilding",
+			EnvVars: []string{"PLUGIN_AUTO_CACHE_GO_CACHE_TRIM"},
+		},
+		&cli.StringFlag{
+			Name:    "auto-detect-rules",
+			Usage:   "repository configuration file declaring additional auto detection rules",
+			Value:   autodetect.DefaultRulesFile,
+			EnvVars: []string{"PLUGIN_AUTO_CACHE_RULES"},
+		},
+		&cli.StringFlag{
+			Name:    "auto-detect-bazel-disk-cache-size",
+			Usage:   "size the Bazel disk cache is trimmed to before rebuilding, e.g. 10GB, least recently used entries are removed first",
+			EnvVars: []string{"PLUGIN_AUTO_CACHE_BAZEL_DISK_CACHE_SIZE"},
+		},
 		&cli.StringFlag{
 			Name:    "account-id",
 			Usage:   "account-id used for automatic key generation",
@@ -295,24 +360,90 @@ func main() {
 		// RESTORE-KEYS
 		&cli.StringFlag{
 			Name:    "archive-format, arcfmt",
-			Usage:   "archive format to use to store the cache directories (tar, gzip, zstd)",
+			Usage:   "archive format to use to store the cache directories (tar, gzip, zstd, lz4, xz, zip)",
 			Value:   archive.DefaultArchiveFormat,
 			EnvVars: []string{"PLUGIN_ARCHIVE_FORMAT"},
 		},
 		&cli.IntFlag{
 			Name: "compression-level, cpl",
 
-			Usage: `compression level to use for gzip/zstd compression when archive-format specified as gzip/zstd
+			Usage: `compression level to use for gzip/zstd/lz4/xz/zip compression when archive-format specified as gzip/zstd/lz4/xz/zip
 			(check https://godoc.org/compress/flate#pkg-constants for available options for gzip
 			and https://pkg.go.dev/github.com/klauspost/compress/zstd#EncoderLevelFromZstd for zstd)`,
 			Value:   archive.DefaultCompressionLevel,
 			EnvVars: []string{"PLUGIN_COMPRESSION_LEVEL"},
 		},
+		&cli.IntFlag{
+			Name:    "compression-concurrency",
+			Usage:   "number of goroutines to compress and decompress gzip/zstd archives with, 0 uses all available CPUs, 1 disables parallel gzip",
+			EnvVars: []string{"PLUGIN_COMPRESSION_CONCURRENCY"},
+		},
+		&cli.IntFlag{
+			Name:    "compression-window-size",
+			Usage:   "zstd encoder window size in bytes, must be a power of two, 0 uses the default for the compression level",
+			EnvVars: []string{"PLUGIN_COMPRESSION_WINDOW_SIZE"},
+		},
+		&cli.BoolFlag{
+			Name:    "adaptive-compression",
+			Usage:   "store already compressed files, like jars and wheels, without recompressing them in gzip/zstd/zip archives",
+			EnvVars: []string{"PLUGIN_ADAPTIVE_COMPRESSION"},
+		},
+		&cli.StringFlag{
+			Name:    "zip-compression",
+			Usage:   "compression method of zip archive entries (deflate, zstd, store)",
+			Value:   "deflate",
+			EnvVars: []string{"PLUGIN_ZIP_COMPRESSION"},
+		},
+		&cli.IntFlag{
+			Name:    "extract-concurrency",
+			Usage:   "number of goroutines to write restored files with, 0 uses four per available CPU, 1 writes files sequentially",
+			EnvVars: []string{"PLUGIN_EXTRACT_CONCURRENCY"},
+		},
+		&cli.StringFlag{
+			Name:    "max-archive-size",
+			Usage:   "maximum size of compressed archives, e.g. 10GB, rebuilds and restores of bigger archives fail",
+			EnvVars: []string{"PLUGIN_MAX_ARCHIVE_SIZE"},
+		},
+		&cli.StringFlag{
+			Name:    "max-uncompressed-size",
+			Usage:   "maximum size of archive content, e.g. 20GB, rebuilds and restores of bigger archives fail",
+			EnvVars: []string{"PLUGIN_MAX_UNCOMPRESSED_SIZE"},
+		},
+		&cli.Int64Flag{
+			Name:    "max-entries",
+			Usage:   "maximum number of files, directories and links in archives",
+			EnvVars: []string{"PLUGIN_MAX_ENTRIES"},
+		},
+		&cli.Float64Flag{
+			Name:    "max-compression-ratio",
+			Usage:   "maximum ratio of uncompressed to compressed size of restored archives, e.g. 100",
+			EnvVars: []string{"PLUGIN_MAX_COMPRESSION_RATIO"},
+		},
+		&cli.BoolFlag{
+			Name:    "preserve-owner",
+			Usage:   "restore owners of archived files, requires running as root, otherwise files are owned by the current user",
+			EnvVars: []string{"PLUGIN_PRESERVE_OWNER"},
+		},
+		&cli.StringFlag{
+			Name:    "mode-mask",
+			Usage:   "octal permission bits to clear from modes of restored files, like a umask, e.g. 022",
+			EnvVars: []string{"PLUGIN_MODE_MASK"},
+		},
+		&cli.BoolFlag{
+			Name:    "preserve-mtime",
+			Usage:   "restore modification times of archived files, instead of the time of the restore",
+			EnvVars: []string{"PLUGIN_PRESERVE_MTIME"},
+		},
 		&cli.BoolFlag{
 			Name:    "skip-symlinks, ss",
 			Usage:   "skip symbolic links in archive",
 			EnvVars: []string{"PLUGIN_SKIP_SYMLINKS", "SKIP_SYMLINKS"},
 		},
+		&cli.StringFlag{
+			Name:    "symlink-policy",
+			Usage:   "how to archive symbolic links (preserve, follow, skip, follow-within-root), overrides skip-symlinks",
+			EnvVars: []string{"PLUGIN_SYMLINK_POLICY"},
+		},
 		&cli.BoolFlag{
 			Name:    "debug, d",
 			Usage:   "debug",
@@ -344,6 +475,100 @@ func main() {
 			EnvVars: []string{"PLUGIN_STRICT_KEY_MATCHING"},
 		},
 
+		// Condition flags
+
+		&cli.StringSliceFlag{
+			Name:    "rebuild.branches",
+			Usage:   "only rebuild for runs matching one of given branch glob patterns",
+			EnvVars: []string{"PLUGIN_REBUILD_BRANCHES"},
+		},
+		&cli.StringSliceFlag{
+			Name:    "rebuild.events",
+			Usage:   "only rebuild for runs matching one of given build events",
+			EnvVars: []string{"PLUGIN_REBUILD_EVENTS"},
+		},
+		&cli.StringSliceFlag{
+			Name:    "rebuild.status",
+			Usage:   "only rebuild for runs matching one of given build statuses",
+			EnvVars: []string{"PLUGIN_REBUILD_STATUS"},
+		},
+		&cli.StringSliceFlag{
+			Name:    "rebuild.tags",
+			Usage:   "only rebuild for runs matching one of given tag glob patterns",
+			EnvVars: []string{"PLUGIN_REBUILD_TAGS"},
+		},
+		&cli.StringSliceFlag{
+			Name:    "restore.branches",
+			Usage:   "only restore for runs matching one of given branch glob patterns",
+			EnvVars: []string{"PLUGIN_RESTORE_BRANCHES"},
+		},
+		&cli.StringSliceFlag{
+			Name:    "restore.events",
+			Usage:   "only restore for runs matching one of given build events",
+			EnvVars: []string{"PLUGIN_RESTORE_EVENTS"},
+		},
+		&cli.StringSliceFlag{
+			Name:    "restore.status",
+			Usage:   "only restore for runs matching one of given build statuses",
+			EnvVars: []string{"PLUGIN_RESTORE_STATUS"},
+		},
+		&cli.StringSliceFlag{
+			Name:    "restore.tags",
+			Usage:   "only restore for runs matching one of given tag glob patterns",
+			EnvVars: []string{"PLUGIN_RESTORE_TAGS"},
+		},
+
+		// Security flags
+
+		&cli.StringFlag{
+			Name:    "write-policy",
+			Usage:   "which runs may write to shared cache keys. ('open', 'scoped': untrusted runs write to their own namespace, 'read-only': untrusted runs can not rebuild)",
+			Value:   plugin.DefaultWritePolicy,
+			EnvVars: []string{"PLUGIN_WRITE_POLICY"},
+		},
+		&cli.StringFlag{
+			Name:    "scoped-namespace",
+			Usage:   "namespace template for the writes of untrusted runs when write-policy is scoped (default pr-<number> for pull requests)",
+			EnvVars: []string{"PLUGIN_SCOPED_NAMESPACE"},
+		},
+		&cli.StringSliceFlag{
+			Name:    "trusted-branches",
+			Usage:   "branch glob patterns that are trusted to write to shared cache keys (default all branches)",
+			EnvVars: []string{"PLUGIN_TRUSTED_BRANCHES"},
+		},
+		&cli.StringSliceFlag{
+			Name:    "untrusted-events",
+			Usage:   "build events that are not trusted to write to shared cache keys",
+			Value:   cli.NewStringSlice("pull_request"),
+			EnvVars: []string{"PLUGIN_UNTRUSTED_EVENTS"},
+		},
+		&cli.BoolFlag{
+			Name:    "require-trusted-repo",
+			Usage:   "treat writes from repositories without the trusted flag as untrusted",
+			EnvVars: []string{"PLUGIN_REQUIRE_TRUSTED_REPO"},
+		},
+
+		&cli.StringFlag{
+			Name:    "manifest.signing-key",
+			Usage:   "base64 encoded ed25519 private key to sign cache manifests with on rebuild",
+			EnvVars: []string{"PLUGIN_MANIFEST_SIGNING_KEY"},
+		},
+		&cli.StringFlag{
+			Name:    "manifest.hmac-secret",
+			Usage:   "shared secret to sign cache manifests with on rebuild, and to verify them on restore",
+			EnvVars: []string{"PLUGIN_MANIFEST_HMAC_SECRET"},
+		},
+		&cli.StringSliceFlag{
+			Name:    "manifest.public-keys",
+			Usage:   "base64 encoded ed25519 public keys trusted to sign cache manifests, enables verification on restore",
+			EnvVars: []string{"PLUGIN_MANIFEST_PUBLIC_KEYS"},
+		},
+		&cli.BoolFlag{
+			Name:    "manifest.verify",
+			Usage:   "refuse to restore archives without a manifest signed by a trusted key",
+			EnvVars: []string{"PLUGIN_MANIFEST_VERIFY"},
+		},
+
 		// Backends Configs
 
 		// Shared Config flags
@@ -594,24 +819,127 @@ func main() {
 			EnvVars: []string{"PLUGIN_ENABLE_MULTIPART"},
 		},
 	}
-
-	if err := app.Run(os.Args); err != nil {
-		stdlog.Fatalf("%#v", err)
-	}
 }
 
 // nolint:funlen
 func run(c *cli.Context) error {
-	var logLevel = c.String("log.level")
-	if c.Bool("debug") {
-		logLevel = internal.LogLevelDebug
-	}
-
-	logger := internal.NewLogger(logLevel, c.String("log.format"), "drone-cache-logger")
+	logger := newLogger(c)
 	level.Debug(logger).Log("version", version, "commit", commit, "date", date)
 
 	plg := plugin.New(log.With(logger, "component", "plugin"))
-	plg.Metadata = metadata.Metadata{
+	plg.Metadata = newMetadata(c)
+
+	bc := backendConfig(c)
+	plg.Config = plugin.Config{
+		ArchiveFormat:              c.String("archive-format"),
+		Backend:                    c.String("backend"),
+		CacheKeyTemplate:           c.String("cache-key"),
+		MetricsFile:                c.String("metrics-file"),
+		StateFile:                  c.String("state-file"),
+		CompressionLevel:           c.Int("compression-level"),
+		CompressionConcurrency:     c.Int("compression-concurrency"),
+		CompressionWindowSize:      c.Int("compression-window-size"),
+		AdaptiveCompression:        c.Bool("adaptive-compression"),
+		ZipCompression:             c.String("zip-compression"),
+		ExtractConcurrency:         c.Int("extract-concurrency"),
+		Debug:                      c.Bool("debug"),
+		Mount:                      c.StringSlice("mount"),
+		Rebuild:                    c.Bool("rebuild"),
+		Restore:                    c.Bool("restore"),
+		AutoDetect:                 c.Bool("auto-detect"),
+		AutoDetectEarlyExit:        c.Bool("auto-detect-early-exit"),
+		AutoDetectCargoTarget:      c.Bool("auto-detect-cargo-target"),
+		AutoDetectNodeCache:        c.String("auto-detect-node-cache"),
+		AutoDetectMaxDepth:         c.Int("auto-detect-max-depth"),
+		AutoDetectExcludes:         c.StringSlice("auto-detect-exclude"),
+		AutoDetectCleanup:          c.Bool("auto-detect-cleanup"),
+		AutoDetectGoCacheTrim:      c.Duration("auto-detect-go-cache-trim"),
+		AutoDetectRules:            c.String("auto-detect-rules"),
+		AutoDetectBazelDiskCache:   c.String("auto-detect-bazel-disk-cache-size"),
+		AccountID:                  c.String("account-id"),
+		RemoteRoot:                 c.String("remote-root"),
+		LocalRoot:                  c.String("local-root"),
+		Override:                   c.Bool("override"),
+		FailRestoreIfKeyNotPresent: c.Bool("fail-restore-if-key-not-present"),
+		EnableCacheKeySeparator:    c.Bool("enable-cache-key-separator"),
+		StrictKeyMatching:          c.Bool("strict-key-matching"),
+
+		StorageOperationTimeout: c.Duration("backend.operation-timeout"),
+		FileSystem:              bc.FileSystem,
+		S3:                      bc.S3,
+		Azure:                   bc.Azure,
+		SFTP:                    bc.SFTP,
+		GCS:                     bc.GCS,
+		Harness:                 bc.Harness,
+
+		SkipSymlinks:  c.Bool("skip-symlinks"),
+		SymlinkPolicy: c.String("symlink-policy"),
+
+		RebuildWhen: plugin.Condition{
+			Branches: c.StringSlice("rebuild.branches"),
+			Events:   c.StringSlice("rebuild.events"),
+			Status:   c.StringSlice("rebuild.status"),
+			Tags:     c.StringSlice("rebuild.tags"),
+		},
+		RestoreWhen: plugin.Condition{
+			Branches: c.StringSlice("restore.branches"),
+			Events:   c.StringSlice("restore.events"),
+			Status:   c.StringSlice("restore.status"),
+			Tags:     c.StringSlice("restore.tags"),
+		},
+
+		Policy: plugin.PolicyConfig{
+			WritePolicy:        c.String("write-policy"),
+			ScopedNamespace:    c.String("scoped-namespace"),
+			TrustedBranches:    c.StringSlice("trusted-branches"),
+			UntrustedEvents:    c.StringSlice("untrusted-events"),
+			RequireTrustedRepo: c.Bool("require-trusted-repo"),
+		},
+		Manifest: plugin.ManifestConfig{
+			SigningKey: c.String("manifest.signing-key"),
+			HMACSecret: c.String("manifest.hmac-secret"),
+			PublicKeys: c.StringSlice("manifest.public-keys"),
+			Verify:     c.Bool("manifest.verify"),
+		},
+		Limits: plugin.LimitsConfig{
+			MaxArchiveSize:      c.String("max-archive-size"),
+			MaxUncompressedSize: c.String("max-uncompressed-size"),
+			MaxEntries:          c.Int64("max-entries"),
+			MaxCompressionRatio: c.Float64("max-compression-ratio"),
+		},
+
+		Attributes: plugin.AttributesConfig{
+			PreserveOwner:   c.Bool("preserve-owner"),
+			ModeMask:        c.String("mode-mask"),
+			PreserveModTime: c.Bool("preserve-mtime"),
+		},
+	}
+
+	err := plg.Exec()
+	if err == nil {
+		return nil
+	}
+
+	if c.Bool("exit-code") {
+		// If it is exit-code enabled, always exit with error.
+		level.Warn(logger).Log("msg", "silent fails disabled, exiting with status code on error")
+
+		return err
+	}
+
+	var e plugin.Error
+	if errors.As(err, &e) {
+		// If it is an expected error log it, handle it gracefully,
+		level.Error(logger).Log("err", err)
+
+		return nil
+	}
+
+	return err
+}
+
+func newMetadata(c *cli.Context) metadata.Metadata {
+	return metadata.Metadata{
 		Repo: metadata.Repo{
 			Namespace: c.String("repo.namespace"),
 			Owner:     c.String("repo.owner"),
@@ -623,19 +951,20 @@ func run(c *cli.Context) error {
 			Trusted:   c.Bool("repo.trusted"),
 		},
 		Build: metadata.Build{
-			Number:   c.Int("build.number"),
-			Event:    c.String("build.event"),
-			Status:   c.String("build.status"),
-			Deploy:   c.String("build.deploy"),
-			Created:  int64(c.Int("build.created")),
-			Started:  int64(c.Int("build.started")),
-			Finished: int64(c.Int("build.finished")),
-			Link:     c.String("build.link"),
+			Number:      c.Int("build.number"),
+			PullRequest: c.Int("build.pull-request"),
+			Event:       c.String("build.event"),
+			Status:      c.String("build.status"),
+			Deploy:      c.String("build.deploy"),
+			Created:     int64(c.Int("build.created")),
+			Started:     int64(c.Int("build.started")),
+			Finished:    int64(c.Int("build.finished")),
+			Link:        c.String("build.link"),
 		},
 		Commit: metadata.Commit{
 			Remote:  c.String("remote.url"),
 			Sha:     c.String("commit.sha"),
-			Ref:     c.String("commit.sha"),
+			Ref:     c.String("commit.ref"),
 			Link:    c.String("commit.link"),
 			Branch:  c.String("commit.branch"),
 			Message: c.String("commit.message"),
@@ -646,28 +975,22 @@ func run(c *cli.Context) error {
 			},
 		},
 	}
+}
 
-	plg.Config = plugin.Config{
-		ArchiveFormat:              c.String("archive-format"),
-		Backend:                    c.String("backend"),
-		CacheKeyTemplate:           c.String("cache-key"),
-		MetricsFile:                c.String("metrics-file"),
-		CompressionLevel:           c.Int("compression-level"),
-		Debug:                      c.Bool("debug"),
-		Mount:                      c.StringSlice("mount"),
-		Rebuild:                    c.Bool("rebuild"),
-		Restore:                    c.Bool("restore"),
-		AutoDetect:                 c.Bool("auto-detect"),
-		AutoDetectEarlyExit:        c.Bool("auto-detect-early-exit"),
-		AccountID:                  c.String("account-id"),
-		RemoteRoot:                 c.String("remote-root"),
-		LocalRoot:                  c.String("local-root"),
-		Override:                   c.Bool("override"),
-		FailRestoreIfKeyNotPresent: c.Bool("fail-restore-if-key-not-present"),
-		EnableCacheKeySeparator:    c.Bool("enable-cache-key-separator"),
-		StrictKeyMatching:          c.Bool("strict-key-matching"),
+func newLogger(c *cli.Context) log.Logger {
+	var logLevel = c.String("log.level")
+	if c.Bool("debug") {
+		logLevel = internal.LogLevelDebug
+	}
+
+	return internal.NewLogger(logLevel, c.String("log.format"), "drone-cache-logger")
+}
+
+// nolint:funlen
+func backendConfig(c *cli.Context) backend.Config {
+	return backend.Config{
+		Debug: c.Bool("debug"),
 
-		StorageOperationTimeout: c.Duration("backend.operation-timeout"),
 		FileSystem: filesystem.Config{
 			CacheRoot: c.String("filesystem.cache-root"),
 		},
@@ -730,29 +1053,5 @@ func run(c *cli.Context) error {
 			MultipartThresholdSize: c.Int("multipart.threshold.size"),
 			MultipartEnabled:       c.String("multipart.enabled"),
 		},
-
-		SkipSymlinks: c.Bool("skip-symlinks"),
-	}
-
-	err := plg.Exec()
-	if err == nil {
-		return nil
-	}
-
-	if c.Bool("exit-code") {
-		// If it is exit-code enabled, always exit with error.
-		level.Warn(logger).Log("msg", "silent fails disabled, exiting with status code on error")
-
-		return err
 	}
-
-	var e plugin.Error
-	if errors.As(err, &e) {
-		// If it is an expected error log it, handle it gracefully,
-		level.Error(logger).Log("err", err)
-
-		return nil
-	}
-
-	return err
 }
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/meltwater/drone-cache/internal/metadata"
+	"github.com/meltwater/drone-cache/test"
+
+	"github.com/urfave/cli/v2"
+)
+
+func TestNewMetadata(t *testing.T) {
+	t.Setenv("DRONE_BUILD_EVENT", "tag")
+	t.Setenv("DRONE_COMMIT_SHA", "2f5b3c1")
+	t.Setenv("DRONE_COMMIT_REF", "refs/tags/v1.2.0")
+	t.Setenv("DRONE_COMMIT_BRANCH", "main")
+
+	var m metadata.Metadata
+
+	app := cli.NewApp()
+	app.Flags = flags()
+	app.Action = func(c *cli.Context) error {
+		m = newMetadata(c)
+		return nil
+	}
+
+	test.Ok(t, app.Run([]string{"drone-cache"}))
+	test.Equals(t, "tag", m.Build.Event)
+	test.Equals(t, "2f5b3c1", m.Commit.Sha)
+	test.Equals(t, "refs/tags/v1.2.0", m.Commit.Ref)
+	test.Equals(t, "main", m.Commit.Branch)
+}
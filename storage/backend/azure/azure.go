@@ -147,3 +147,14 @@ func (b *Backend) Exists(ctx context.Context, p string) (bool, error) {
 func (b *Backend) List(ctx context.Context, p string) ([]common.FileEntry, error) {
 	return nil, common.ErrNotImplemented
 }
+
+// Delete removes the object from remote storage.
+func (b *Backend) Delete(ctx context.Context, p string) error {
+	blobURL := b.containerURL.NewBlockBlobURL(p)
+
+	if _, err := blobURL.Delete(ctx, azblob.DeleteSnapshotsOptionInclude, azblob.BlobAccessConditions{}); err != nil {
+		return fmt.Errorf("delete the object, %w", err)
+	}
+
+	return nil
+}
@@ -47,8 +47,8 @@ type Backend interface {
 	// List contents of the given directory by given key from remote storage.
 	List(ctx context.Context, p string) ([]common.FileEntry, error)
 
-	// Implement me!
-	// Delete(ctx context.Context, p string) error
+	// Delete removes the object at the given path from remote storage.
+	Delete(ctx context.Context, p string) error
 }
 
 // FromConfig creates new Backend by initializing  using given configuration.
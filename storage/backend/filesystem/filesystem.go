@@ -134,5 +134,68 @@ func (b *Backend) Exists(ctx context.Context, p string) (bool, error) {
 
 // List contents of the given directory by given key from remote storage.
 func (b *Backend) List(ctx context.Context, p string) ([]common.FileEntry, error) {
-	return nil, common.ErrNotImplemented
+	root, err := filepath.Abs(filepath.Clean(b.cacheRoot))
+	if err != nil {
+		return nil, fmt.Errorf("absolute path, %w", err)
+	}
+
+	// NOTICE: Object storages match keys by prefix, so walk the closest directory and filter by prefix.
+	prefix := filepath.ToSlash(p)
+	dir := filepath.Join(root, filepath.FromSlash(path.Dir(prefix)))
+	if strings.HasSuffix(prefix, "/") {
+		dir = filepath.Join(root, filepath.FromSlash(prefix))
+	}
+
+	var entries []common.FileEntry
+
+	err = filepath.Walk(dir, func(fp string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		if fi.IsDir() {
+			return nil
+		}
+
+		rel, err := filepath.Rel(root, fp)
+		if err != nil {
+			return fmt.Errorf("relative path <%s>, %w", fp, err)
+		}
+
+		rel = filepath.ToSlash(rel)
+		if !strings.HasPrefix(rel, prefix) {
+			return nil
+		}
+
+		entries = append(entries, common.FileEntry{
+			Path:         rel,
+			Size:         fi.Size(),
+			LastModified: fi.ModTime(),
+		})
+
+		return nil
+	})
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("list the objects, %w", err)
+	}
+
+	return entries, nil
+}
+
+// Delete removes the object from remote storage.
+func (b *Backend) Delete(ctx context.Context, p string) error {
+	path, err := filepath.Abs(filepath.Clean(filepath.Join(b.cacheRoot, p)))
+	if err != nil {
+		return fmt.Errorf("absolute path, %w", err)
+	}
+
+	if err := os.Remove(path); err != nil {
+		return fmt.Errorf("delete the object, %w", err)
+	}
+
+	return nil
 }
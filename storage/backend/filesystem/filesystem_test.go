@@ -37,6 +37,35 @@ func TestRoundTrip(t *testing.T) {
 	test.Equals(t, true, exists)
 }
 
+func TestListAndDelete(t *testing.T) {
+	t.Parallel()
+
+	backend, cleanUp := setup(t)
+	t.Cleanup(cleanUp)
+
+	for _, p := range []string{"key/a", "key/b/c", "key1/a"} {
+		test.Ok(t, backend.Put(context.TODO(), p, strings.NewReader(p)))
+	}
+
+	entries, err := backend.List(context.TODO(), "key/")
+	test.Ok(t, err)
+	test.Equals(t, 2, len(entries))
+
+	entries, err = backend.List(context.TODO(), "key")
+	test.Ok(t, err)
+	test.Equals(t, 3, len(entries))
+
+	entries, err = backend.List(context.TODO(), "idonotexist/")
+	test.Ok(t, err)
+	test.Equals(t, 0, len(entries))
+
+	test.Ok(t, backend.Delete(context.TODO(), "key/b/c"))
+
+	exists, err := backend.Exists(context.TODO(), "key/b/c")
+	test.Ok(t, err)
+	test.Equals(t, false, exists)
+}
+
 // Helpers
 
 func setup(t *testing.T) (*Backend, func()) {
@@ -221,6 +221,15 @@ func (b *Backend) List(ctx context.Context, p string) ([]common.FileEntry, error
 	return entries, nil
 }
 
+// Delete removes the object from remote storage.
+func (b *Backend) Delete(ctx context.Context, p string) error {
+	if err := b.client.Bucket(b.bucket).Object(p).Delete(ctx); err != nil {
+		return fmt.Errorf("delete the object, %w", err)
+	}
+
+	return nil
+}
+
 // Helpers
 
 func setAuthenticationMethod(l log.Logger, c Config, opts []option.ClientOption) []option.ClientOption {
@@ -677,6 +677,11 @@ func (b *Backend) List(ctx context.Context, prefix string) ([]common.FileEntry,
 	return entries, err
 }
 
+// Delete is not supported by the cache service yet.
+func (b *Backend) Delete(ctx context.Context, key string) error {
+	return common.ErrNotImplemented
+}
+
 type ListBucketResult struct {
 	XMLName               xml.Name  `xml:"ListBucketResult"`
 	Contents              []Content `xml:"Contents"`
@@ -217,6 +217,20 @@ func (b *Backend) List(ctx context.Context, p string) ([]common.FileEntry, error
 	return entries, err
 }
 
+// Delete removes the object from remote storage.
+func (b *Backend) Delete(ctx context.Context, p string) error {
+	in := &s3.DeleteObjectInput{
+		Bucket: aws.String(b.bucket),
+		Key:    aws.String(p),
+	}
+
+	if _, err := b.client.DeleteObjectWithContext(ctx, in); err != nil {
+		return fmt.Errorf("delete the object, %w", err)
+	}
+
+	return nil
+}
+
 // AssumeRole logic
 func assumeRole(roleArn, roleSessionName, externalID string) *credentials.Credentials {
 
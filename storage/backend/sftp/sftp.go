@@ -171,6 +171,32 @@ func (b *Backend) List(ctx context.Context, p string) ([]common.FileEntry, error
 	return nil, common.ErrNotImplemented
 }
 
+// Delete removes the object from remote storage.
+func (b *Backend) Delete(ctx context.Context, p string) error {
+	path, err := filepath.Abs(filepath.Clean(filepath.Join(b.cacheRoot, p)))
+	if err != nil {
+		return fmt.Errorf("generate absolute path, %w", err)
+	}
+
+	// NOTICE: Buffered, so that the goroutine does not leak when the context is done first.
+	errCh := make(chan error, 1)
+
+	go func() {
+		defer close(errCh)
+
+		if err := b.client.Remove(path); err != nil {
+			errCh <- fmt.Errorf("delete the object, %w", err)
+		}
+	}()
+
+	select {
+	case err := <-errCh:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
 // Helpers
 
 func authMethod(c Config) ([]ssh.AuthMethod, error) {
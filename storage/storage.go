@@ -78,7 +78,8 @@ func (s *storage) List(p string) ([]common.FileEntry, error) {
 
 // Delete deletes the object from remote storage.
 func (s *storage) Delete(p string) error {
-	// Implement me!
-	// Make sure consumer utilizes context.
-	return nil
+	ctx, cancel := context.WithTimeout(context.Background(), s.timeout)
+	defer cancel()
+
+	return s.b.Delete(ctx, p)
 }